@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance"
+	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/config"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/router"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/cbr"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/database"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/exchangeratesapi"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/server"
@@ -19,11 +21,86 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		clean, err := reconcile()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if !clean {
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatalln(err)
 	}
 }
 
+// reconcile checks ledger once, prints report and reports whether ledger is consistent.
+func reconcile() (bool, error) {
+	cfg, err := config.New()
+	if err != nil {
+		return false, err
+	}
+
+	db, err := database.New(context.Background(), cfg.PgURL, cfg.TxMaxRetries)
+	if err != nil {
+		return false, err
+	}
+
+	defer db.Close()
+
+	report, err := balanceDB.NewBalanceDB(db, cfg.TxIsoLevel).CreateReconciliationReport(context.Background())
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Reconciliation report #%d\n", report.ID)
+
+	for _, m := range report.Mismatches {
+		fmt.Printf("account #%d %s: expected %s, actual %s\n", m.AccountID, m.Currency, m.Expected, m.Actual)
+	}
+
+	for _, o := range report.Orphans {
+		fmt.Printf("transaction #%d: account #%d doesn't exist\n", o.TransactionID, o.AccountID)
+	}
+
+	fmt.Printf("%d balance mismatches, %d orphan transactions\n", report.MismatchCount, report.OrphanCount)
+
+	return report.IsClean(), nil
+}
+
+// newRateProviders returns chains of current and historical rate providers set in config.
+// Historical provider is nil if no provider set in config supports historical rates.
+func newRateProviders(cfg *config.Config, currencyAPI *exchangeratesapi.ExchangerAPI) (convertor.RateProvider, convertor.HistoricalRateProvider) {
+	providers := make([]convertor.RateProvider, 0, len(cfg.RateProviders))
+	historical := make([]convertor.HistoricalRateProvider, 0, len(cfg.RateProviders))
+
+	for _, name := range cfg.RateProviders {
+		switch name {
+		case "exchangeratesapi":
+			p := convertor.NewExchangeratesAPIProvider(currencyAPI)
+			providers = append(providers, p)
+			historical = append(historical, p)
+		case "cbr":
+			p := convertor.NewCBRProvider(cbr.New(cfg.CBRURL))
+			providers = append(providers, p)
+			historical = append(historical, p)
+		case "file":
+			providers = append(providers, convertor.NewFileProvider(cfg.RatesFile))
+		}
+	}
+
+	if len(historical) == 0 {
+		return convertor.NewFallbackProvider(providers...), nil
+	}
+
+	return convertor.NewFallbackProvider(providers...), convertor.NewHistoricalFallbackProvider(historical...)
+}
+
 func run() error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
@@ -35,34 +112,51 @@ func run() error {
 
 	addr := ":" + cfg.Port
 
-	db, err := database.New(context.Background(), cfg.PgURL)
+	db, err := database.New(context.Background(), cfg.PgURL, cfg.TxMaxRetries)
 	if err != nil {
 		return err
 	}
 
-	currencyAPI := exchangeratesapi.New(cfg.EAPIToken)
+	currencyAPI := exchangeratesapi.New(cfg.EAPIToken, cfg.EAPIURL)
+
+	currentRates, historicalRates := newRateProviders(cfg, currencyAPI)
 
-	currency, err := currencyAPI.GetCurrencyList()
+	rateStore := balanceDB.NewBalanceDB(db, cfg.TxIsoLevel)
+	rateProvider := convertor.NewStoringProvider(currentRates, rateStore)
+
+	rates, err := convertor.LoadRates(context.Background(), rateProvider, rateStore)
 	if err != nil {
 		return err
 	}
 
-	cConvertor := convertor.NewCurrencyConvertor(currency.Rates)
+	cConvertor := convertor.NewCurrencyConvertorFromRates(rates)
 
 	r := router.New()
 
-	service := balance.New(db, cConvertor)
+	service := balance.New(db, cConvertor, historicalRates, cfg.IdempotencyKeyTTL, cfg.RatesStaleAfter, cfg.TxIsoLevel)
 
 	r.Route("/api", func(r chi.Router) {
 		service.Routes(r)
 	})
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	go service.RunIdempotencyKeyPurger(jobsCtx, cfg.IdempotencyPurge)
+	go service.RunHoldSweeper(jobsCtx, cfg.HoldSweepInterval)
+	go service.RunBalanceSnapshotter(jobsCtx, cfg.SnapshotInterval)
+	go service.RunTurnoverRollup(jobsCtx, cfg.RollupInterval)
+	go service.RunReconciler(jobsCtx, cfg.ReconcileInterval)
+	go cConvertor.RunRefresher(jobsCtx, cfg.RatesInterval, rateProvider)
+
 	srv := server.New(addr, r)
 
 	fmt.Printf("Service has been started on %s\n", addr)
 
 	<-quit
 
+	stopJobs()
+
 	ctx, shutdown := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdown()
 
@@ -5,5 +5,6 @@ go 1.16
 require (
 	github.com/go-chi/chi/v5 v5.0.4
 	github.com/jackc/pgconn v1.10.0
+	github.com/jackc/pgtype v1.8.1
 	github.com/jackc/pgx/v4 v4.13.0
 )
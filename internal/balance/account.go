@@ -0,0 +1,130 @@
+package balance
+
+import (
+	"errors"
+	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4"
+	"net/http"
+	"strconv"
+)
+
+// CreateAccount POST /api/accounts
+func (s *Service) CreateAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	a, err := s.db.CreateAccount(ctx)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Error while create account"))
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(newAccountResponse(a)))
+}
+
+// GetAccount GET /api/accounts/{accountID}
+func (s *Service) GetAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "accountID"))
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	a, err := s.db.GetBalanceAccountByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Account not found"))
+		} else {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Error while get account data"))
+		}
+
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, newAccountResponse(a))
+}
+
+// FreezeAccount POST /api/accounts/{accountID}/freeze
+func (s *Service) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	s.updateAccountStatus(w, r, model.AccountStatusFrozen)
+}
+
+// UnfreezeAccount POST /api/accounts/{accountID}/unfreeze
+func (s *Service) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	s.updateAccountStatus(w, r, model.AccountStatusActive)
+}
+
+// CloseAccount POST /api/accounts/{accountID}/close
+func (s *Service) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	s.updateAccountStatus(w, r, model.AccountStatusClosed)
+}
+
+func (s *Service) updateAccountStatus(w http.ResponseWriter, r *http.Request, status string) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "accountID"))
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	a, err := s.db.UpdateAccountStatus(ctx, id, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, balanceDB.ErrAccountNotFound):
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Account not found"))
+		case errors.Is(err, balanceDB.ErrAccountClosed):
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(15, "Account is closed"))
+		case errors.Is(err, balanceDB.ErrAccountBalanceNotZero):
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(16, "Account with non-zero balance can't be closed"))
+		default:
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Error while update account"))
+		}
+
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(newAccountResponse(a)))
+}
+
+// writeAccountStatusError writes response if err is caused by account status and reports whether it was written.
+func writeAccountStatusError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, balanceDB.ErrAccountFrozen):
+		jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(14, "Account is frozen"))
+	case errors.Is(err, balanceDB.ErrAccountClosed):
+		jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(15, "Account is closed"))
+	default:
+		return false
+	}
+
+	return true
+}
+
+func newAccountResponse(a *model.Account) *v1.Account {
+	response := v1.Account{
+		ID:      a.ID,
+		Status:  a.Status,
+		Wallets: []*v1.Wallet{},
+	}
+
+	for _, w := range a.Wallets {
+		response.Wallets = append(response.Wallets, newWalletResponse(w))
+	}
+
+	return &response
+}
+
+func newWalletResponse(w *model.Wallet) *v1.Wallet {
+	return &v1.Wallet{
+		Currency:  w.Currency,
+		Balance:   w.Balance,
+		Available: w.Available(),
+		Held:      w.Held,
+	}
+}
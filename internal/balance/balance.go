@@ -1,28 +1,51 @@
 package balance
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
 	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
 	"github.com/jackc/pgx/v4"
 	"net/http"
 	"strconv"
+	"time"
+)
+
+// defaultCurrency is a currency of operation if it is not set in request.
+const defaultCurrency = "RUB"
+
+// Limits of transaction metadata.
+const (
+	maxMetadataBytes = 4096
+	maxMetadataDepth = 5
 )
 
 // Service balance.
 type Service struct {
-	db         *balanceDB.BalanceDB
-	cConvertor *convertor.CurrencyConvertor
+	db                *balanceDB.BalanceDB
+	cConvertor        *convertor.CurrencyConvertor
+	idempotencyKeyTTL time.Duration
+	historicalRates   convertor.HistoricalRateProvider
+	ratesStaleAfter   time.Duration
 }
 
-// New returns new balance service.
-func New(db *database.DB, cc *convertor.CurrencyConvertor) *Service {
+// New returns new balance service. Balances are changed in transactions with isoLevel.
+// Missing historical rates are fetched from hr, historical rate mode is rejected if hr is nil.
+// Rates of cc fetched earlier than ratesStaleAfter ago are stale.
+func New(db *database.DB, cc *convertor.CurrencyConvertor, hr convertor.HistoricalRateProvider, idempotencyKeyTTL time.Duration,
+	ratesStaleAfter time.Duration, isoLevel pgx.TxIsoLevel) *Service {
 	return &Service{
-		db:         balanceDB.NewBalanceDB(db),
-		cConvertor: cc,
+		db:                balanceDB.NewBalanceDB(db, isoLevel),
+		cConvertor:        cc,
+		historicalRates:   hr,
+		idempotencyKeyTTL: idempotencyKeyTTL,
+		ratesStaleAfter:   ratesStaleAfter,
 	}
 }
 
@@ -38,12 +61,30 @@ func (s *Service) GetBalance(w http.ResponseWriter, r *http.Request) {
 
 	currency := r.URL.Query().Get("currency")
 	if currency == "" {
-		currency = "RUB"
+		currency = defaultCurrency
+	}
+
+	if err := s.validateCurrency(currency); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+		return
+	}
+
+	var balanceAccount *model.Account
+
+	if at := r.URL.Query().Get("at"); at != "" {
+		t, parseErr := time.Parse(time.RFC3339, at)
+		if parseErr != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "At param must be RFC 3339 time"))
+			return
+		}
+
+		balanceAccount, err = s.db.GetBalanceAccountAt(ctx, id, t.Local())
+	} else {
+		balanceAccount, err = s.db.GetBalanceAccountByID(ctx, id)
 	}
 
-	balanceAccount, err := s.db.GetBalanceAccountByID(ctx, id)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, balanceDB.ErrAccountNotFound) {
 			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Account not found"))
 			return
 		} else {
@@ -52,28 +93,42 @@ func (s *Service) GetBalance(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if currency == "RUB" {
-		jsonutil.MarshalResponse(w, http.StatusOK, v1.GetBalanceResponse{
-			Balance:  balanceAccount.Balance,
-			Currency: currency,
-		})
-	} else {
-		c, err := s.cConvertor.Convert(balanceAccount.Balance, currency)
-		if err != nil {
-			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, err.Error()))
-			return
+	response := v1.GetBalanceResponse{
+		Currency: currency,
+		Wallets:  []*v1.Wallet{},
+	}
+
+	for _, wallet := range balanceAccount.Wallets {
+		wr := newWalletResponse(wallet)
+
+		for _, v := range []struct {
+			amount money.Amount
+			total  *money.Amount
+		}{
+			{wr.Balance, &response.Balance},
+			{wr.Available, &response.Available},
+			{wr.Held, &response.Held},
+		} {
+			c, err := s.cConvertor.Convert(v.amount, wallet.Currency, currency)
+			if err != nil {
+				jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, err.Error()))
+				return
+			}
+
+			*v.total += c
 		}
 
-		jsonutil.MarshalResponse(w, http.StatusOK, v1.GetBalanceResponse{
-			Balance:  c,
-			Currency: currency,
-		})
+		response.Wallets = append(response.Wallets, wr)
 	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, response)
 }
 
 type controlBalanceRequest struct {
-	Amount  float64 `json:"amount"`
-	Comment string  `json:"comment"`
+	Amount   money.Amount    `json:"amount"`
+	Currency string          `json:"currency"`
+	Comment  string          `json:"comment"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
 }
 
 func (r *controlBalanceRequest) validate() error {
@@ -81,6 +136,14 @@ func (r *controlBalanceRequest) validate() error {
 		return errors.New("amount must to be not 0")
 	}
 
+	if err := validateMetadata(&r.Metadata); err != nil {
+		return err
+	}
+
+	if r.Currency == "" {
+		r.Currency = defaultCurrency
+	}
+
 	return nil
 }
 
@@ -107,16 +170,70 @@ func (s *Service) ControlBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.db.UpdateBalance(ctx, id, req.Amount, req.Comment)
+	if err := s.validateCurrency(req.Currency); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+		return
+	}
+
+	key, err := s.newIdempotencyKey(r, req)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	transactionID, err := s.db.UpdateBalance(ctx, id, req.Currency, req.Amount, req.Comment, req.Metadata, key)
 	if err != nil {
-		if errors.Is(err, balanceDB.ErrBalanceMustBePositive) {
+		if writeAccountStatusError(w, err) {
+			return
+		}
+
+		if errors.Is(err, balanceDB.ErrAccountNotFound) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Account not found"))
+			return
+		} else if errors.Is(err, balanceDB.ErrBalanceMustBePositive) {
 			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(5, "Balance can't be negative"))
 			return
+		} else if errors.Is(err, balanceDB.ErrIdempotencyKeyConflict) {
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(8, "Idempotency key already used with another request"))
+			return
+		} else if errors.Is(err, balanceDB.ErrIdempotencyKeyLegacy) {
+			jsonutil.MarshalResponse(w, http.StatusUnprocessableEntity, jsonutil.NewError(17, "Idempotency key was saved before transaction results were stored and can't be replayed"))
+			return
 		} else {
 			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Error while update account"))
 			return
 		}
 	}
 
-	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(1))
+	markIdempotentReplay(w, key)
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(v1.CreateTransactionResponse{
+		TransactionID: transactionID,
+	}))
+}
+
+// validateMetadata checks limits of metadata, JSON null is the same as no metadata.
+func validateMetadata(m *json.RawMessage) error {
+	if string(*m) == "null" {
+		*m = nil
+	}
+
+	if len(*m) == 0 {
+		return nil
+	}
+
+	if err := jsonutil.ValidateObject(*m, maxMetadataBytes, maxMetadataDepth); err != nil {
+		return fmt.Errorf("metadata %w", err)
+	}
+
+	return nil
+}
+
+// validateCurrency returns error if currency is unknown to convertor.
+func (s *Service) validateCurrency(currency string) error {
+	if !s.cConvertor.IsSupported(currency) {
+		return fmt.Errorf("currency %s is not supported", currency)
+	}
+
+	return nil
 }
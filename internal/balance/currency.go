@@ -0,0 +1,56 @@
+package balance
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// GetCurrencies GET /api/currencies
+func (s *Service) GetCurrencies(w http.ResponseWriter, r *http.Request) {
+	// Rates are copied, so list isn't mixed from tables before and after concurrent refresh.
+	rates := s.cConvertor.CurrentRates()
+	cc := convertor.NewCurrencyConvertorFromRates(rates)
+
+	response := v1.GetCurrenciesResponse{
+		Base:       defaultCurrency,
+		Source:     rates.Source,
+		UpdatedAt:  rates.UpdatedAt,
+		FetchedAt:  rates.FetchedAt,
+		Stale:      time.Since(rates.FetchedAt) > s.ratesStaleAfter,
+		Currencies: []*v1.Currency{},
+	}
+
+	// Table of rate provider can miss base currency, then currencies are listed without rates.
+	baseSupported := cc.IsSupported(defaultCurrency)
+
+	for code := range rates.Rates {
+		if !cc.IsSupported(code) {
+			continue
+		}
+
+		c := &v1.Currency{Code: code}
+
+		if baseSupported {
+			rate, _, err := cc.Rate(code, defaultCurrency)
+			if err != nil {
+				jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, "Cannot get rate of "+code))
+				return
+			}
+
+			s := rate.FloatString(convertor.RateScale)
+			c.Rate = &s
+		}
+
+		response.Currencies = append(response.Currencies, c)
+	}
+
+	sort.Slice(response.Currencies, func(i, j int) bool {
+		return response.Currencies[i].Code < response.Currencies[j].Code
+	})
+
+	jsonutil.MarshalResponse(w, http.StatusOK, &response)
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"github.com/jackc/pgx/v4"
+	"sort"
+)
+
+// ErrAccountFrozen error.
+var ErrAccountFrozen = errors.New("account is frozen")
+
+// ErrAccountClosed error.
+var ErrAccountClosed = errors.New("account is closed")
+
+// ErrAccountBalanceNotZero error.
+var ErrAccountBalanceNotZero = errors.New("account balance is not zero")
+
+const accountColumns = `id, type, status`
+
+// CreateAccount in database.
+func (db *BalanceDB) CreateAccount(ctx context.Context) (*model.Account, error) {
+	var a *model.Account
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		var err error
+
+		a, err = scanAccount(tx.QueryRow(ctx, `
+			INSERT INTO
+				accounts
+				(type, status)
+			VALUES
+				($1, $2)
+			RETURNING `+accountColumns,
+			model.AccountTypeUser, model.AccountStatusActive))
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// UpdateAccountStatus in database. Closed account can't change status,
+// and account can be closed only with zero balance.
+func (db *BalanceDB) UpdateAccountStatus(ctx context.Context, id int, status string) (*model.Account, error) {
+	var a *model.Account
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		var err error
+
+		a, err = db.lockUserAccountInTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if a.Status == model.AccountStatusClosed {
+			return ErrAccountClosed
+		}
+
+		if status == model.AccountStatusClosed {
+			var hasBalance bool
+
+			err := tx.QueryRow(ctx, `
+				SELECT
+					EXISTS (SELECT 1 FROM wallets WHERE account_id = $1 AND balance <> 0)
+			`, id).Scan(&hasBalance)
+			if err != nil {
+				return err
+			}
+
+			if hasBalance {
+				return ErrAccountBalanceNotZero
+			}
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE
+				accounts
+			SET
+				status = $1
+			WHERE
+				id = $2
+		`, status, id)
+		if err != nil {
+			return err
+		}
+
+		a.Status = status
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// GetWalletsInTx returns all wallets of account.
+func (db *BalanceDB) GetWalletsInTx(ctx context.Context, tx pgx.Tx, id int) ([]*model.Wallet, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT
+			account_id, currency, balance, held
+		FROM
+			wallets
+		WHERE
+			account_id = $1
+		ORDER BY currency
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var wallets []*model.Wallet
+
+	for rows.Next() {
+		var w model.Wallet
+
+		if err := rows.Scan(&w.AccountID, &w.Currency, &w.Balance, &w.Held); err != nil {
+			return nil, err
+		}
+
+		wallets = append(wallets, &w)
+	}
+
+	return wallets, rows.Err()
+}
+
+// lockUserAccountInTx locks user account row until end of transaction.
+// Wallets of account are changed only under this lock.
+func (db *BalanceDB) lockUserAccountInTx(ctx context.Context, tx pgx.Tx, id int) (*model.Account, error) {
+	a, err := scanAccount(tx.QueryRow(ctx, `
+		SELECT
+			`+accountColumns+`
+		FROM
+			accounts
+		WHERE
+			id = $1 AND type = $2
+		FOR UPDATE
+	`, id, model.AccountTypeUser))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// lockUserAccountsInTx locks user account rows in ascending order of id, so concurrent transactions
+// locking the same accounts can't deadlock. Ids of not found accounts, e.g. system ones, are missing in result.
+func (db *BalanceDB) lockUserAccountsInTx(ctx context.Context, tx pgx.Tx, ids ...int) (map[int]*model.Account, error) {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	accounts := make(map[int]*model.Account, len(sorted))
+
+	for _, id := range sorted {
+		if _, ok := accounts[id]; ok {
+			continue
+		}
+
+		a, err := db.lockUserAccountInTx(ctx, tx, id)
+		if errors.Is(err, ErrAccountNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		accounts[id] = a
+	}
+
+	return accounts, nil
+}
+
+// checkAccountStatus returns error if account status doesn't allow debit or credit.
+func checkAccountStatus(a *model.Account, debit bool) error {
+	switch a.Status {
+	case model.AccountStatusClosed:
+		return ErrAccountClosed
+	case model.AccountStatusFrozen:
+		if debit {
+			return ErrAccountFrozen
+		}
+	}
+
+	return nil
+}
+
+// changeBalanceInTx adds delta to balance of user account wallet, wallet is created on first credit.
+// Balances of system accounts are not stored.
+func (db *BalanceDB) changeBalanceInTx(ctx context.Context, tx pgx.Tx, id int, currency string, delta money.Amount) error {
+	// Existing wallet is updated first: CHECK constraints are tested on proposed row of INSERT before
+	// ON CONFLICT is resolved, so debit can't be made as insert of negative balance.
+	tag, err := tx.Exec(ctx, `
+		UPDATE
+			wallets
+		SET
+			balance = balance + $3
+		WHERE
+			account_id = $1 AND currency = $2
+	`, id, currency, delta)
+	if err != nil {
+		if isCheckViolation(err) {
+			return ErrBalanceMustBePositive
+		}
+
+		return err
+	}
+
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	if delta < 0 {
+		var accountType string
+
+		err := tx.QueryRow(ctx, `SELECT type FROM accounts WHERE id = $1`, id).Scan(&accountType)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		if accountType == model.AccountTypeUser {
+			return ErrBalanceMustBePositive
+		}
+
+		return nil
+	}
+
+	// Wallet can be created by concurrent transaction after update, so conflict is resolved as update.
+	_, err = tx.Exec(ctx, `
+		INSERT INTO
+			wallets
+			(account_id, currency, balance)
+		SELECT
+			id, $2, $3
+		FROM
+			accounts
+		WHERE
+			id = $1 AND type = $4
+		ON CONFLICT (account_id, currency) DO UPDATE SET
+			balance = wallets.balance + EXCLUDED.balance
+	`, id, currency, delta, model.AccountTypeUser)
+
+	return err
+}
+
+func scanAccount(row pgx.Row) (*model.Account, error) {
+	var a model.Account
+
+	err := row.Scan(&a.ID, &a.Type, &a.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
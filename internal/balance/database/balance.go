@@ -2,22 +2,27 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+	"time"
 )
 
 // BalanceDB struct.
 type BalanceDB struct {
 	db *database.DB
+	// isoLevel is an isolation level of transactions which change balances.
+	isoLevel pgx.TxIsoLevel
 }
 
-// NewBalanceDB returns new BalanceDB.
-func NewBalanceDB(db *database.DB) *BalanceDB {
-	return &BalanceDB{db: db}
+// NewBalanceDB returns new BalanceDB which changes balances in transactions with isoLevel.
+func NewBalanceDB(db *database.DB, isoLevel pgx.TxIsoLevel) *BalanceDB {
+	return &BalanceDB{db: db, isoLevel: isoLevel}
 }
 
 // ErrAccountNotFound error.
@@ -32,51 +37,64 @@ var ErrSenderNotExist = errors.New("sender not exist")
 // ErrReceiverNotExist error.
 var ErrReceiverNotExist = errors.New("receiver not exist")
 
-// GetBalanceAccountByID from database.
+// ErrTransactionNotFound error.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+const historyColumns = `th.id, th.id_from, th.id_to, th.amount, th.currency, ` +
+	`coalesce(th.amount_to, 0), th.currency_to, th.fx_rate::text, th.fx_rate_at, th.comment, th.metadata::text, th.reversal_of, th.created_at`
+
+// GetBalanceAccountByID returns account with all its wallets from database.
 func (db *BalanceDB) GetBalanceAccountByID(ctx context.Context, id int) (*model.Account, error) {
-	var a model.Account
+	var a *model.Account
 
-	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
-		err := tx.QueryRow(ctx, `
+	err := db.db.InTx(ctx, pgx.RepeatableRead, func(tx pgx.Tx) error {
+		var err error
+
+		a, err = scanAccount(tx.QueryRow(ctx, `
 			SELECT
-				id, balance
+				`+accountColumns+`
 			FROM
 				accounts
 			WHERE
-				id = $1
-		`, id).Scan(&a.ID, &a.Balance)
-
+				id = $1 AND type = $2
+		`, id, model.AccountTypeUser))
 		if err != nil {
 			return err
 		}
 
-		return nil
+		a.Wallets, err = db.GetWalletsInTx(ctx, tx, id)
+		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &a, nil
+	return a, nil
 }
 
-// GetHistory from database.
-func (db *BalanceDB) GetHistory(ctx context.Context, id int, limit int, offset int, sortBy string, sortOrder string) ([]*model.TransactionHistory, int, error) {
+// GetHistory from database. sortBy and sortOrder must be validated by caller.
+func (db *BalanceDB) GetHistory(ctx context.Context, f *model.HistoryFilter, limit int, offset int, sortBy string, sortOrder string) ([]*model.TransactionHistory, int, error) {
 	var ths []*model.TransactionHistory
 	var count int
 
+	q := newHistoryQuery(f)
+	limitArg, offsetArg := q.arg(limit), q.arg(offset)
+
 	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		ths = nil
+
 		rows, err := tx.Query(ctx, fmt.Sprintf(`
 			SELECT 
-				id_from, id_to, amount, comment, created_at, count(*) OVER() AS count
+				`+historyColumns+`, count(*) OVER() AS count
 			FROM
-				transaction_history
+				transaction_history th
 			WHERE
-				id_from = $1 OR id_to = $1
-			ORDER BY %s %s
-			LIMIT $2
-			OFFSET $3
-		`, sortBy, sortOrder), id, limit, offset)
+				%s
+			ORDER BY %s
+			LIMIT %s
+			OFFSET %s
+		`, q.whereSQL(), q.orderBySQL(sortBy, sortOrder), limitArg, offsetArg), q.args...)
 		if err != nil {
 			return err
 		}
@@ -84,14 +102,12 @@ func (db *BalanceDB) GetHistory(ctx context.Context, id int, limit int, offset i
 		defer rows.Close()
 
 		for rows.Next() {
-			var th model.TransactionHistory
-
-			err := rows.Scan(&th.IDFrom, &th.IDTo, &th.Amount, &th.Comment, &th.CreatedAt, &count)
+			th, err := scanHistory(rows, &count)
 			if err != nil {
 				return err
 			}
 
-			ths = append(ths, &th)
+			ths = append(ths, th)
 		}
 
 		return rows.Err()
@@ -101,54 +117,166 @@ func (db *BalanceDB) GetHistory(ctx context.Context, id int, limit int, offset i
 		return nil, 0, err
 	}
 
-	if len(ths) <= 0 {
+	// Empty result of filtered history doesn't mean that there is no account.
+	if len(ths) <= 0 && f.IsEmpty() {
 		return nil, 0, ErrAccountNotFound
 	}
 
 	return ths, count, nil
 }
 
-// UpdateBalance in database.
-func (db *BalanceDB) UpdateBalance(ctx context.Context, id int, amount float64, comment string) error {
+// GetHistoryPage returns up to limit transactions of account which follow cursor after in history sorted by sortBy.
+// Nil after means first page. Unlike GetHistory, total count isn't computed. Sort by relevance isn't supported.
+func (db *BalanceDB) GetHistoryPage(ctx context.Context, f *model.HistoryFilter, limit int, sortBy string, sortOrder string, after *model.HistoryCursor) ([]*model.TransactionHistory, error) {
+	var ths []*model.TransactionHistory
+
+	q := newAccountHistoryQuery(f)
+
+	if after != nil {
+		cmp := "<"
+		if sortOrder == "ASC" {
+			cmp = ">"
+		}
+
+		var value interface{} = after.CreatedAt
+		if sortBy == "amount" {
+			value = after.Amount
+		}
+
+		q.where(fmt.Sprintf("(ah.%s, ah.transaction_id) %s (%s, %s)", sortBy, cmp, q.arg(value), q.arg(after.ID)))
+	}
+
+	limitArg := q.arg(limit)
+
+	// Rows are read in order of account_history index on (account_id, sortBy, transaction_id),
+	// so page is an index range scan from cursor.
 	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
-		r, err := tx.Exec(ctx, `
-			UPDATE
-				accounts
-			SET 
-				balance = balance + $1
+		ths = nil
+
+		rows, err := tx.Query(ctx, fmt.Sprintf(`
+			SELECT
+				`+historyColumns+`
+			FROM
+				account_history ah
+			JOIN
+				transaction_history th ON th.id = ah.transaction_id
 			WHERE
-				id = $2
-		`, amount, id)
+				%s
+			ORDER BY ah.%s %s, ah.transaction_id %s
+			LIMIT %s
+		`, q.whereSQL(), sortBy, sortOrder, sortOrder, limitArg), q.args...)
 		if err != nil {
-			if pgerr, ok := err.(*pgconn.PgError); ok {
-				if pgerr.Code == "23514" {
-					return ErrBalanceMustBePositive
-				}
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			th, err := scanHistory(rows)
+			if err != nil {
+				return err
 			}
 
-			return err
+			ths = append(ths, th)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ths, nil
+}
+
+// GetTransaction from database.
+func (db *BalanceDB) GetTransaction(ctx context.Context, id int64) (*model.TransactionHistory, error) {
+	var th *model.TransactionHistory
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		var err error
+
+		th, err = db.getTransactionInTx(ctx, tx, id, false)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return th, nil
+}
+
+func (db *BalanceDB) getTransactionInTx(ctx context.Context, tx pgx.Tx, id int64, forUpdate bool) (*model.TransactionHistory, error) {
+	query := `
+		SELECT
+			` + historyColumns + `
+		FROM
+			transaction_history th
+		WHERE
+			th.id = $1
+	`
+
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	th, err := scanHistory(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTransactionNotFound
 		}
 
-		if r.RowsAffected() == 0 {
-			if amount >= 0 {
-				id, err = db.CreateUserInTx(ctx, tx, amount)
-				if err != nil {
-					return err
-				}
-			} else {
-				return ErrBalanceMustBePositive
+		return nil, err
+	}
+
+	return th, nil
+}
+
+// UpdateBalance in database and returns id of created transaction.
+// If key is not nil, result is stored with it in the same transaction.
+func (db *BalanceDB) UpdateBalance(ctx context.Context, id int, currency string, amount money.Amount, comment string, metadata json.RawMessage, key *model.IdempotencyKey) (int64, error) {
+	var transactionID int64
+
+	err := db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		if key != nil {
+			if err := db.AcquireIdempotencyKeyInTx(ctx, tx, key); err != nil {
+				return err
+			}
+
+			if key.Replayed {
+				transactionID = key.TransactionID
+				return nil
 			}
 		}
 
+		a, err := db.lockUserAccountInTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := checkAccountStatus(a, amount < 0); err != nil {
+			return err
+		}
+
+		if err := db.changeBalanceInTx(ctx, tx, id, currency, amount); err != nil {
+			return err
+		}
+
 		th := model.TransactionHistory{
-			Amount:  amount,
-			Comment: comment,
+			Amount:   amount,
+			Currency: currency,
+			Comment:  comment,
+			Metadata: metadata,
 		}
 
 		if amount < 0 {
 			th.IDFrom = id
-			th.IDTo = 0
+			th.IDTo = model.SystemSinkAccountID
+			th.Amount = -amount
 		} else {
+			th.IDFrom = model.SystemSourceAccountID
 			th.IDTo = id
 		}
 
@@ -158,55 +286,69 @@ func (db *BalanceDB) UpdateBalance(ctx context.Context, id int, amount float64,
 			return err
 		}
 
+		transactionID = th.ID
+
+		if key != nil {
+			key.TransactionID = th.ID
+			return db.SaveIdempotencyResultInTx(ctx, tx, key)
+		}
+
 		return nil
 	})
 
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return transactionID, nil
 }
 
-func (db *BalanceDB) Transfer(ctx context.Context, h *model.TransactionHistory) error {
-	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
-		rs, err := tx.Exec(ctx, `
-			UPDATE
-				accounts
-			SET
-				balance = balance - $1
-			WHERE
-				id = $2
-		`, h.Amount, h.IDFrom)
-		if err != nil {
-			if pgerr, ok := err.(*pgconn.PgError); ok {
-				if pgerr.Code == "23514" {
-					return ErrBalanceMustBePositive
-				}
+// Transfer money between accounts in database. Id of created transaction is set to h.ID.
+// If key is not nil, result is stored with it in the same transaction.
+func (db *BalanceDB) Transfer(ctx context.Context, h *model.TransactionHistory, key *model.IdempotencyKey) error {
+	err := db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		if key != nil {
+			if err := db.AcquireIdempotencyKeyInTx(ctx, tx, key); err != nil {
+				return err
+			}
+
+			if key.Replayed {
+				h.ID = key.TransactionID
+				return nil
 			}
+		}
 
+		accounts, err := db.lockUserAccountsInTx(ctx, tx, h.IDFrom, h.IDTo)
+		if err != nil {
 			return err
 		}
 
-		if rs.RowsAffected() == 0 {
+		sender, ok := accounts[h.IDFrom]
+		if !ok {
 			return ErrSenderNotExist
 		}
 
-		rr, err := tx.Exec(ctx, `
-			UPDATE
-				accounts
-			SET
-				balance = balance + $1
-			WHERE
-				id = $2
-		`, h.Amount, h.IDTo)
+		receiver, ok := accounts[h.IDTo]
+		if !ok {
+			return ErrReceiverNotExist
+		}
 
-		if err != nil {
+		if err := checkAccountStatus(sender, true); err != nil {
 			return err
 		}
 
-		if rr.RowsAffected() == 0 {
-			return ErrReceiverNotExist
+		if err := checkAccountStatus(receiver, false); err != nil {
+			return err
+		}
+
+		if err := db.changeBalanceInTx(ctx, tx, h.IDFrom, h.Currency, -h.Amount); err != nil {
+			return err
+		}
+
+		amountTo, currencyTo := h.ReceivedAmount()
+
+		if err := db.changeBalanceInTx(ctx, tx, h.IDTo, currencyTo, amountTo); err != nil {
+			return err
 		}
 
 		err = db.CreateHistoryLog(ctx, tx, h)
@@ -214,6 +356,11 @@ func (db *BalanceDB) Transfer(ctx context.Context, h *model.TransactionHistory)
 			return err
 		}
 
+		if key != nil {
+			key.TransactionID = h.ID
+			return db.SaveIdempotencyResultInTx(ctx, tx, key)
+		}
+
 		return nil
 	})
 
@@ -224,34 +371,98 @@ func (db *BalanceDB) Transfer(ctx context.Context, h *model.TransactionHistory)
 	return nil
 }
 
-// CreateUserInTx is a function to create new user in DB.
-func (db *BalanceDB) CreateUserInTx(ctx context.Context, tx pgx.Tx, amount float64) (int, error) {
-	var id int
+// CreateHistoryLog is a function to create new history log in DB.
+// It writes journal entry and its balanced postings.
+func (db *BalanceDB) CreateHistoryLog(ctx context.Context, tx pgx.Tx, h *model.TransactionHistory) error {
+	// Untyped nils are written as NULL for transaction without currency exchange or metadata.
+	var amountTo, currencyTo, rate, rateAt, metadata interface{}
+
+	if h.FX != nil {
+		amountTo, currencyTo, rate, rateAt = h.FX.AmountTo, h.FX.CurrencyTo, h.FX.Rate, h.FX.RateAt
+	}
+
+	if len(h.Metadata) > 0 {
+		metadata = string(h.Metadata)
+	}
 
 	err := tx.QueryRow(ctx, `
-		INSERT INTO
-			accounts(balance)
+		INSERT INTO 
+			transaction_history
+			(id_from, id_to, amount, currency, amount_to, currency_to, fx_rate, fx_rate_at, comment, metadata, reversal_of, created_at)
 		VALUES
-			($1)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::jsonb, $11, $12)
 		RETURNING id
-	`, amount).Scan(&id)
+	`, h.IDFrom, h.IDTo, h.Amount, h.Currency, amountTo, currencyTo, rate, rateAt, h.Comment, metadata, h.ReversalOf, h.CreatedAt).Scan(&h.ID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range h.Postings() {
+		if err := db.CreatePostingInTx(ctx, tx, &p); err != nil {
+			return err
+		}
+	}
 
-	return id, err
+	// Every account of postings gets one row with sort keys of transaction for keyset pagination.
+	_, err = tx.Exec(ctx, `
+		INSERT INTO
+			account_history
+			(account_id, transaction_id, amount, created_at)
+		SELECT DISTINCT
+			account_id, $1::bigint, $2::numeric, $3::timestamp
+		FROM
+			postings
+		WHERE
+			transaction_id = $1
+	`, h.ID, h.Amount, h.CreatedAt)
+
+	return err
 }
 
-// CreateHistoryLog is a function to create new history log in DB.
-func (db *BalanceDB) CreateHistoryLog(ctx context.Context, tx pgx.Tx, h *model.TransactionHistory) error {
-	_, err := tx.Exec(ctx, `
-		INSERT INTO 
-			transaction_history
-			(id_from, id_to, amount, comment, created_at)
+// CreatePostingInTx is a function to create new ledger posting in DB.
+func (db *BalanceDB) CreatePostingInTx(ctx context.Context, tx pgx.Tx, p *model.Posting) error {
+	return tx.QueryRow(ctx, `
+		INSERT INTO
+			postings
+			(transaction_id, account_id, amount, currency, created_at)
 		VALUES
 			($1, $2, $3, $4, $5)
-	`, h.IDFrom, h.IDTo, h.Amount, h.Comment, h.CreatedAt)
+		RETURNING id
+	`, p.TransactionID, p.AccountID, p.Amount, p.Currency, p.CreatedAt).Scan(&p.ID)
+}
 
+// scanHistory scans historyColumns and extra columns to dest.
+func scanHistory(row pgx.Row, dest ...interface{}) (*model.TransactionHistory, error) {
+	var th model.TransactionHistory
+	var fx model.FXConversion
+	var currencyTo, rate, metadata *string
+	var rateAt *time.Time
+
+	err := row.Scan(append([]interface{}{
+		&th.ID, &th.IDFrom, &th.IDTo, &th.Amount, &th.Currency,
+		&fx.AmountTo, &currencyTo, &rate, &rateAt, &th.Comment, &metadata, &th.ReversalOf, &th.CreatedAt,
+	}, dest...)...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if metadata != nil {
+		th.Metadata = json.RawMessage(*metadata)
+	}
+
+	if currencyTo != nil && rate != nil && rateAt != nil {
+		fx.CurrencyTo = *currencyTo
+		fx.Rate = *rate
+		fx.RateAt = *rateAt
+		th.FX = &fx
+	}
+
+	return &th, nil
+}
+
+// isCheckViolation reports whether err is violation of CHECK constraint, e.g. negative balance.
+func isCheckViolation(err error) bool {
+	var pgerr *pgconn.PgError
+	return errors.As(err, &pgerr) && pgerr.Code == "23514"
 }
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrNoCurrencyRates is returned when no rate table is stored.
+var ErrNoCurrencyRates = errors.New("no currency rates")
+
+// SaveCurrencyRates stores rate table fetched from provider.
+func (db *BalanceDB) SaveCurrencyRates(ctx context.Context, r *convertor.Rates) error {
+	rates, err := json.Marshal(r.Rates)
+	if err != nil {
+		return err
+	}
+
+	return db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO
+				currency_rates
+				(source, base, rates, updated_at, fetched_at)
+			VALUES
+				($1, $2, $3::jsonb, $4, $5)
+		`, r.Source, r.Base, string(rates), r.UpdatedAt.Local(), r.FetchedAt.Local())
+
+		return err
+	})
+}
+
+// GetLatestCurrencyRates returns the most recently fetched rate table.
+func (db *BalanceDB) GetLatestCurrencyRates(ctx context.Context) (*convertor.Rates, error) {
+	var r convertor.Rates
+	var rates string
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			SELECT
+				source, base, rates::text, updated_at, fetched_at
+			FROM
+				currency_rates
+			ORDER BY
+				fetched_at DESC, id DESC
+			LIMIT 1
+		`).Scan(&r.Source, &r.Base, &rates, &r.UpdatedAt, &r.FetchedAt)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoCurrencyRates
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(rates), &r.Rates); err != nil {
+		return nil, err
+	}
+
+	r.UpdatedAt = asLocal(r.UpdatedAt)
+	r.FetchedAt = asLocal(r.FetchedAt)
+
+	return &r, nil
+}
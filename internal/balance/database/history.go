@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/jackc/pgx/v4"
+	"strconv"
+	"strings"
+)
+
+// historyFetchSize is a count of rows fetched from cursor at once by StreamHistory.
+const historyFetchSize = 500
+
+// StreamHistory calls fn for every transaction of history in order. Rows are fetched from server-side cursor
+// in batches, so history is never loaded in memory entirely. Streaming stops on first error of fn or ctx cancel.
+// sortBy and sortOrder must be validated by caller.
+func (db *BalanceDB) StreamHistory(ctx context.Context, f *model.HistoryFilter, sortBy string, sortOrder string, fn func(th *model.TransactionHistory) error) error {
+	q := newHistoryQuery(f)
+
+	// Read only transaction can't fail with serialization error, so it is never retried after fn was called.
+	return db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, fmt.Sprintf(`
+			DECLARE history_export NO SCROLL CURSOR FOR
+			SELECT
+				`+historyColumns+`
+			FROM
+				transaction_history th
+			WHERE
+				%s
+			ORDER BY %s
+		`, q.whereSQL(), q.orderBySQL(sortBy, sortOrder)), q.args...)
+		if err != nil {
+			return err
+		}
+
+		for {
+			fetched, err := fetchHistory(ctx, tx, fn)
+			if err != nil {
+				return err
+			}
+
+			if fetched < historyFetchSize {
+				return nil
+			}
+		}
+	})
+}
+
+// GetHistoryDates returns dates in YYYY-MM-DD format of transactions of history which are not in currency.
+func (db *BalanceDB) GetHistoryDates(ctx context.Context, f *model.HistoryFilter, currency string) ([]string, error) {
+	q := newHistoryQuery(f)
+	q.where("th.currency <> " + q.arg(currency))
+
+	var dates []string
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		dates = nil
+
+		rows, err := tx.Query(ctx, fmt.Sprintf(`
+			SELECT DISTINCT
+				to_char(th.created_at, 'YYYY-MM-DD')
+			FROM
+				transaction_history th
+			WHERE
+				%s
+		`, q.whereSQL()), q.args...)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var date string
+
+			if err := rows.Scan(&date); err != nil {
+				return err
+			}
+
+			dates = append(dates, date)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dates, nil
+}
+
+// fetchHistory fetches next batch of history_export cursor and returns count of fetched rows.
+func fetchHistory(ctx context.Context, tx pgx.Tx, fn func(th *model.TransactionHistory) error) (int, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM history_export", historyFetchSize))
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	var fetched int
+
+	for rows.Next() {
+		th, err := scanHistory(rows)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := fn(th); err != nil {
+			return 0, err
+		}
+
+		fetched++
+	}
+
+	return fetched, rows.Err()
+}
+
+// likeEscaper escapes wildcards of LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// historyQuery builds parameterized WHERE clause of account history query.
+type historyQuery struct {
+	conditions []string
+	args       []interface{}
+	// search is a full-text query expression, it is set if filter has search query.
+	search string
+}
+
+// newHistoryQuery returns query with conditions of filter f.
+func newHistoryQuery(f *model.HistoryFilter) *historyQuery {
+	q := &historyQuery{}
+
+	account := q.arg(f.AccountID)
+	q.where("EXISTS (SELECT 1 FROM postings p WHERE p.transaction_id = th.id AND p.account_id = " + account + ")")
+	q.filter(f, account, "th.created_at")
+
+	return q
+}
+
+// newAccountHistoryQuery returns query with conditions of filter f for transaction_history th
+// joined with account_history ah. Account and period are matched by ah, so its indexes are used.
+func newAccountHistoryQuery(f *model.HistoryFilter) *historyQuery {
+	q := &historyQuery{}
+
+	account := q.arg(f.AccountID)
+	q.where("ah.account_id = " + account)
+	q.filter(f, account, "ah.created_at")
+
+	return q
+}
+
+// filter adds conditions of filter f except account one. account is a placeholder of account id,
+// createdAt is a column which period is matched by.
+func (q *historyQuery) filter(f *model.HistoryFilter, account string, createdAt string) {
+	if f.From != nil {
+		q.where(createdAt + " >= " + q.arg(*f.From))
+	}
+
+	if f.To != nil {
+		q.where(createdAt + " < " + q.arg(*f.To))
+	}
+
+	switch f.Direction {
+	case model.HistoryDirectionIncoming:
+		q.where("th.id_to = " + account + " AND th.id_from > 0")
+	case model.HistoryDirectionOutgoing:
+		q.where("th.id_from = " + account + " AND th.id_to > 0")
+	case model.HistoryDirectionSystem:
+		q.where("(th.id_from < 0 OR th.id_to < 0)")
+	}
+
+	if f.CounterpartyID != 0 {
+		counterparty := q.arg(f.CounterpartyID)
+		q.where("((th.id_from = " + account + " AND th.id_to = " + counterparty + ") OR " +
+			"(th.id_to = " + account + " AND th.id_from = " + counterparty + "))")
+	}
+
+	if f.MinAmount != nil {
+		q.where("th.amount >= " + q.arg(*f.MinAmount))
+	}
+
+	if f.MaxAmount != nil {
+		q.where("th.amount <= " + q.arg(*f.MaxAmount))
+	}
+
+	if f.Comment != "" {
+		q.where("th.comment ILIKE '%' || " + q.arg(likeEscaper.Replace(f.Comment)) + " || '%'")
+	}
+
+	if f.MetadataKey != "" {
+		if len(f.MetadataValue) > 0 {
+			q.where("th.metadata @> jsonb_build_object(" + q.arg(f.MetadataKey) + "::text, " + q.arg(string(f.MetadataValue)) + "::jsonb)")
+		} else {
+			q.where("th.metadata ? " + q.arg(f.MetadataKey))
+		}
+	}
+
+	if f.Search != "" {
+		// Configurations must be the same as in comment_tsv column.
+		search := q.arg(f.Search)
+		q.search = "(websearch_to_tsquery('russian', " + search + ") || websearch_to_tsquery('english', " + search + "))"
+		q.where("th.comment_tsv @@ " + q.search)
+	}
+}
+
+// arg adds query param and returns its placeholder.
+func (q *historyQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *historyQuery) where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+// orderBySQL returns ORDER BY expression with id as tie-breaker. Sort by relevance requires search query.
+func (q *historyQuery) orderBySQL(sortBy string, sortOrder string) string {
+	column := "th." + sortBy
+	if sortBy == model.HistorySortByRelevance {
+		column = "ts_rank(th.comment_tsv, " + q.search + ")"
+	}
+
+	return column + " " + sortOrder + ", th.id " + sortOrder
+}
+
+// whereSQL returns all conditions joined with AND.
+func (q *historyQuery) whereSQL() string {
+	return strings.Join(q.conditions, "\n\t\t\t\tAND ")
+}
@@ -0,0 +1,322 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// ErrHoldNotFound error.
+var ErrHoldNotFound = errors.New("hold not found")
+
+// ErrHoldNotActive error.
+var ErrHoldNotActive = errors.New("hold is not active")
+
+const holdColumns = `id, account_id, amount, currency, status, comment, transaction_id, created_at, expires_at, updated_at`
+
+// expiredHoldsBatchSize is a max count of holds released by one ReleaseExpiredHolds call.
+const expiredHoldsBatchSize = 100
+
+// CreateHold reserves money on account.
+func (db *BalanceDB) CreateHold(ctx context.Context, h *model.Hold) error {
+	return db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		a, err := db.lockUserAccountInTx(ctx, tx, h.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkAccountStatus(a, true); err != nil {
+			return err
+		}
+
+		r, err := tx.Exec(ctx, `
+			UPDATE
+				wallets
+			SET
+				held = held + $1
+			WHERE
+				account_id = $2 AND currency = $3
+		`, h.Amount, h.AccountID, h.Currency)
+		if err != nil {
+			if isCheckViolation(err) {
+				return ErrBalanceMustBePositive
+			}
+
+			return err
+		}
+
+		if r.RowsAffected() == 0 {
+			// Wallet in this currency doesn't exist, so nothing to hold.
+			return ErrBalanceMustBePositive
+		}
+
+		return tx.QueryRow(ctx, `
+			INSERT INTO
+				holds
+				(account_id, amount, currency, status, comment, created_at, expires_at, updated_at)
+			VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, h.AccountID, h.Amount, h.Currency, h.Status, h.Comment, h.CreatedAt, h.ExpiresAt, h.UpdatedAt).Scan(&h.ID)
+	})
+}
+
+// GetHold from database.
+func (db *BalanceDB) GetHold(ctx context.Context, id int64) (*model.Hold, error) {
+	var h *model.Hold
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		var err error
+
+		h, err = db.getHoldInTx(ctx, tx, id, false)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// CaptureHold writes off reserved money from account to system sink.
+func (db *BalanceDB) CaptureHold(ctx context.Context, id int64) (*model.Hold, error) {
+	var h *model.Hold
+
+	err := db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		var err error
+
+		h, err = db.getHoldInTx(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		if h.Status != model.HoldStatusActive || h.IsExpired(now) {
+			return ErrHoldNotActive
+		}
+
+		a, err := db.lockUserAccountInTx(ctx, tx, h.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkAccountStatus(a, true); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE
+				wallets
+			SET
+				balance = balance - $1,
+				held = held - $1
+			WHERE
+				account_id = $2 AND currency = $3
+		`, h.Amount, h.AccountID, h.Currency)
+		if err != nil {
+			return err
+		}
+
+		th := model.TransactionHistory{
+			IDFrom:    h.AccountID,
+			IDTo:      model.SystemSinkAccountID,
+			Amount:    h.Amount,
+			Currency:  h.Currency,
+			Comment:   h.Comment,
+			CreatedAt: now,
+		}
+
+		if err := db.CreateHistoryLog(ctx, tx, &th); err != nil {
+			return err
+		}
+
+		h.Status = model.HoldStatusCaptured
+		h.TransactionID = &th.ID
+		h.UpdatedAt = now
+
+		return db.updateHoldStatusInTx(ctx, tx, h)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// ReleaseHold returns reserved money to available balance.
+func (db *BalanceDB) ReleaseHold(ctx context.Context, id int64) (*model.Hold, error) {
+	var h *model.Hold
+
+	err := db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		var err error
+
+		h, err = db.getHoldInTx(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		if h.Status != model.HoldStatusActive {
+			return ErrHoldNotActive
+		}
+
+		if _, err := db.lockUserAccountInTx(ctx, tx, h.AccountID); err != nil {
+			return err
+		}
+
+		return db.releaseHoldInTx(ctx, tx, h, time.Now())
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// ReleaseExpiredHolds releases batch of holds expired at t and returns count of released holds.
+func (db *BalanceDB) ReleaseExpiredHolds(ctx context.Context, t time.Time) (int, error) {
+	var count int
+
+	err := db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		count = 0
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				`+holdColumns+`
+			FROM
+				holds
+			WHERE
+				status = $1 AND expires_at <= $2
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		`, model.HoldStatusActive, t, expiredHoldsBatchSize)
+		if err != nil {
+			return err
+		}
+
+		var holds []*model.Hold
+		var accountIDs []int
+
+		for rows.Next() {
+			h, err := scanHold(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			holds = append(holds, h)
+			accountIDs = append(accountIDs, h.AccountID)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// Holds are ordered by id, not by account, so all accounts are locked in ascending order before
+		// any wallet is changed, as transfers do.
+		if _, err := db.lockUserAccountsInTx(ctx, tx, accountIDs...); err != nil {
+			return err
+		}
+
+		for _, h := range holds {
+			if err := db.releaseHoldInTx(ctx, tx, h, t); err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// releaseHoldInTx returns held money to wallet, account of hold must be locked by caller.
+func (db *BalanceDB) releaseHoldInTx(ctx context.Context, tx pgx.Tx, h *model.Hold, t time.Time) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE
+			wallets
+		SET
+			held = held - $1
+		WHERE
+			account_id = $2 AND currency = $3
+	`, h.Amount, h.AccountID, h.Currency)
+	if err != nil {
+		return err
+	}
+
+	h.Status = model.HoldStatusReleased
+	h.UpdatedAt = t
+
+	return db.updateHoldStatusInTx(ctx, tx, h)
+}
+
+func (db *BalanceDB) getHoldInTx(ctx context.Context, tx pgx.Tx, id int64, forUpdate bool) (*model.Hold, error) {
+	query := `
+		SELECT
+			` + holdColumns + `
+		FROM
+			holds
+		WHERE
+			id = $1
+	`
+
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	h, err := scanHold(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrHoldNotFound
+		}
+
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (db *BalanceDB) updateHoldStatusInTx(ctx context.Context, tx pgx.Tx, h *model.Hold) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE
+			holds
+		SET
+			status = $1,
+			transaction_id = $2,
+			updated_at = $3
+		WHERE
+			id = $4
+	`, h.Status, h.TransactionID, h.UpdatedAt, h.ID)
+
+	return err
+}
+
+func scanHold(row pgx.Row) (*model.Hold, error) {
+	var h model.Hold
+	var comment *string
+
+	err := row.Scan(&h.ID, &h.AccountID, &h.Amount, &h.Currency, &h.Status, &comment, &h.TransactionID, &h.CreatedAt, &h.ExpiresAt, &h.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment != nil {
+		h.Comment = *comment
+	}
+
+	return &h, nil
+}
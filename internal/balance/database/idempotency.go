@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// ErrIdempotencyKeyConflict error.
+var ErrIdempotencyKeyConflict = errors.New("idempotency key already used with another request")
+
+// ErrIdempotencyKeyLegacy is returned for key saved before transaction ids were stored, its result can't be replayed.
+var ErrIdempotencyKeyLegacy = errors.New("idempotency key predates stored transaction results")
+
+// AcquireIdempotencyKeyInTx reserves idempotency key in transaction.
+// If key was already used with the same request, stored transaction id is loaded and k.Replayed is set.
+func (db *BalanceDB) AcquireIdempotencyKeyInTx(ctx context.Context, tx pgx.Tx, k *model.IdempotencyKey) error {
+	_, err := tx.Exec(ctx, `
+		DELETE FROM
+			idempotency_keys
+		WHERE
+			key = $1 AND expires_at <= $2
+	`, k.Key, k.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Concurrent request with the same key waits here until the first one is committed or rolled back.
+	r, err := tx.Exec(ctx, `
+		INSERT INTO
+			idempotency_keys
+			(key, request_hash, created_at, expires_at)
+		VALUES
+			($1, $2, $3, $4)
+		ON CONFLICT (key) DO NOTHING
+	`, k.Key, k.RequestHash, k.CreatedAt, k.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if r.RowsAffected() == 1 {
+		return nil
+	}
+
+	var requestHash string
+	var transactionID *int64
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			request_hash, transaction_id
+		FROM
+			idempotency_keys
+		WHERE
+			key = $1
+	`, k.Key).Scan(&requestHash, &transactionID)
+	if err != nil {
+		return err
+	}
+
+	if requestHash != k.RequestHash {
+		return ErrIdempotencyKeyConflict
+	}
+
+	if transactionID == nil {
+		return ErrIdempotencyKeyLegacy
+	}
+
+	k.TransactionID = *transactionID
+	k.Replayed = true
+
+	return nil
+}
+
+// SaveIdempotencyResultInTx stores id of transaction created by request made with idempotency key.
+func (db *BalanceDB) SaveIdempotencyResultInTx(ctx context.Context, tx pgx.Tx, k *model.IdempotencyKey) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE
+			idempotency_keys
+		SET
+			transaction_id = $1
+		WHERE
+			key = $2
+	`, k.TransactionID, k.Key)
+
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys deletes keys expired before t and returns count of deleted keys.
+func (db *BalanceDB) PurgeExpiredIdempotencyKeys(ctx context.Context, t time.Time) (int64, error) {
+	var count int64
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		r, err := tx.Exec(ctx, `
+			DELETE FROM
+				idempotency_keys
+			WHERE
+				expires_at < $1
+		`, t)
+		if err != nil {
+			return err
+		}
+
+		count = r.RowsAffected()
+
+		return nil
+	})
+
+	return count, err
+}
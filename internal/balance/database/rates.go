@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
+// GetExchangeRates returns stored rates of date in YYYY-MM-DD format and date of rates returned by provider for it.
+// Empty map means there are no rates of date.
+func (db *BalanceDB) GetExchangeRates(ctx context.Context, date string) (map[string]float64, string, error) {
+	var rates map[string]float64
+	var sourceDate string
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		rates = map[string]float64{}
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				currency, rate, source_date::text
+			FROM
+				exchange_rates
+			WHERE
+				date = $1::date
+		`, date)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var currency string
+			var rate float64
+
+			if err := rows.Scan(&currency, &rate, &sourceDate); err != nil {
+				return err
+			}
+
+			rates[currency] = rate
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rates, sourceDate, nil
+}
+
+// SaveExchangeRates stores rates of date in YYYY-MM-DD format returned by provider as rates of sourceDate.
+// Already stored rates are kept.
+func (db *BalanceDB) SaveExchangeRates(ctx context.Context, date string, sourceDate string, rates map[string]float64) error {
+	return db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		for currency, rate := range rates {
+			if rate <= 0 {
+				continue
+			}
+
+			_, err := tx.Exec(ctx, `
+				INSERT INTO
+					exchange_rates
+					(date, currency, rate, source_date)
+				VALUES
+					($1::date, $2, $3, $4::date)
+				ON CONFLICT DO NOTHING
+			`, date, currency, rate, sourceDate)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrReportNotFound error.
+var ErrReportNotFound = errors.New("reconciliation report not found")
+
+const reportColumns = `id, mismatch_count, orphan_count, created_at`
+
+// CreateReconciliationReport checks that wallet balances are equal to net of postings and that history
+// doesn't point at missing accounts, and saves found inconsistencies. All checks read the same snapshot.
+func (db *BalanceDB) CreateReconciliationReport(ctx context.Context) (*model.ReconciliationReport, error) {
+	var r *model.ReconciliationReport
+
+	err := db.db.InTx(ctx, pgx.RepeatableRead, func(tx pgx.Tx) error {
+		r = &model.ReconciliationReport{}
+		r.Prepare()
+
+		err := tx.QueryRow(ctx, `
+			INSERT INTO
+				reconciliation_reports
+				(mismatch_count, orphan_count, created_at)
+			VALUES
+				(0, 0, $1)
+			RETURNING id
+		`, r.CreatedAt).Scan(&r.ID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			INSERT INTO
+				reconciliation_mismatches
+				(report_id, account_id, currency, expected, actual)
+			SELECT
+				$1, coalesce(w.account_id, p.account_id), coalesce(w.currency, p.currency),
+				coalesce(p.net, 0), coalesce(w.balance, 0)
+			FROM
+				wallets w
+				FULL JOIN (
+					SELECT
+						account_id, currency, sum(amount) AS net
+					FROM
+						postings
+					WHERE
+						account_id > 0
+					GROUP BY account_id, currency
+				) p ON p.account_id = w.account_id AND p.currency = w.currency
+			WHERE
+				coalesce(p.net, 0) <> coalesce(w.balance, 0)
+			RETURNING account_id, currency, expected, actual
+		`, r.ID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var m model.BalanceMismatch
+
+			if err := rows.Scan(&m.AccountID, &m.Currency, &m.Expected, &m.Actual); err != nil {
+				rows.Close()
+				return err
+			}
+
+			r.Mismatches = append(r.Mismatches, &m)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, `
+			INSERT INTO
+				reconciliation_orphans
+				(report_id, transaction_id, account_id)
+			SELECT
+				$1, th.id, coalesce(x.account_id, 0)
+			FROM
+				transaction_history th
+				CROSS JOIN LATERAL (VALUES (th.id_from), (th.id_to)) x (account_id)
+			WHERE
+				NOT EXISTS (SELECT 1 FROM accounts a WHERE a.id = x.account_id)
+			RETURNING transaction_id, account_id
+		`, r.ID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var o model.OrphanTransaction
+
+			if err := rows.Scan(&o.TransactionID, &o.AccountID); err != nil {
+				rows.Close()
+				return err
+			}
+
+			r.Orphans = append(r.Orphans, &o)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		r.MismatchCount, r.OrphanCount = len(r.Mismatches), len(r.Orphans)
+
+		_, err = tx.Exec(ctx, `
+			UPDATE
+				reconciliation_reports
+			SET
+				mismatch_count = $1,
+				orphan_count = $2
+			WHERE
+				id = $3
+		`, r.MismatchCount, r.OrphanCount, r.ID)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetReconciliationReports returns latest reports without details.
+func (db *BalanceDB) GetReconciliationReports(ctx context.Context, limit int) ([]*model.ReconciliationReport, error) {
+	var reports []*model.ReconciliationReport
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		reports = nil
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				`+reportColumns+`
+			FROM
+				reconciliation_reports
+			ORDER BY id DESC
+			LIMIT $1
+		`, limit)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			r, err := scanReport(rows)
+			if err != nil {
+				return err
+			}
+
+			reports = append(reports, r)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// GetReconciliationReport returns report with details.
+func (db *BalanceDB) GetReconciliationReport(ctx context.Context, id int64) (*model.ReconciliationReport, error) {
+	var r *model.ReconciliationReport
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		var err error
+
+		r, err = scanReport(tx.QueryRow(ctx, `
+			SELECT
+				`+reportColumns+`
+			FROM
+				reconciliation_reports
+			WHERE
+				id = $1
+		`, id))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrReportNotFound
+			}
+
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				account_id, currency, expected, actual
+			FROM
+				reconciliation_mismatches
+			WHERE
+				report_id = $1
+			ORDER BY account_id, currency
+		`, id)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var m model.BalanceMismatch
+
+			if err := rows.Scan(&m.AccountID, &m.Currency, &m.Expected, &m.Actual); err != nil {
+				rows.Close()
+				return err
+			}
+
+			r.Mismatches = append(r.Mismatches, &m)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(ctx, `
+			SELECT
+				transaction_id, account_id
+			FROM
+				reconciliation_orphans
+			WHERE
+				report_id = $1
+			ORDER BY transaction_id, account_id
+		`, id)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var o model.OrphanTransaction
+
+			if err := rows.Scan(&o.TransactionID, &o.AccountID); err != nil {
+				return err
+			}
+
+			r.Orphans = append(r.Orphans, &o)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func scanReport(row pgx.Row) (*model.ReconciliationReport, error) {
+	var r model.ReconciliationReport
+
+	err := row.Scan(&r.ID, &r.MismatchCount, &r.OrphanCount, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
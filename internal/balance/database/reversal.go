@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"github.com/jackc/pgx/v4"
+	"math/big"
+)
+
+// ErrReversalExceedsAmount error.
+var ErrReversalExceedsAmount = errors.New("reversal exceeds not reversed amount of transaction")
+
+// ErrReverseOfReversal error.
+var ErrReverseOfReversal = errors.New("reversal can't be reversed")
+
+// ErrAmountTooSmall error.
+var ErrAmountTooSmall = errors.New("amount is too small to convert")
+
+// ReverseTransaction creates compensating transaction which moves amount back from receiver to sender
+// of original transaction. Amount is in currency of original sender, zero amount reverses whole not reversed amount.
+// Cross-currency transaction is reversed by its original rate.
+// Id of created transaction is set to h.ID.
+// If key is not nil, result is stored with it in the same transaction.
+func (db *BalanceDB) ReverseTransaction(ctx context.Context, h *model.TransactionHistory, key *model.IdempotencyKey) error {
+	req := *h
+
+	return db.db.InTx(ctx, db.isoLevel, func(tx pgx.Tx) error {
+		// h is filled from request again, because transaction can be retried.
+		*h = req
+
+		if key != nil {
+			if err := db.AcquireIdempotencyKeyInTx(ctx, tx, key); err != nil {
+				return err
+			}
+
+			if key.Replayed {
+				h.ID = key.TransactionID
+				return nil
+			}
+		}
+
+		// Lock of original transaction serializes concurrent reversals of it.
+		orig, err := db.getTransactionInTx(ctx, tx, *h.ReversalOf, true)
+		if err != nil {
+			return err
+		}
+
+		if orig.ReversalOf != nil {
+			return ErrReverseOfReversal
+		}
+
+		// Reversal of cross-currency transaction is cross-currency too: amount is taken from receiver
+		// in orig.FX.CurrencyTo, and amount_to is returned to sender in orig.Currency.
+		var reversedFrom, reversedTo money.Amount
+
+		err = tx.QueryRow(ctx, `
+			SELECT
+				coalesce(sum(amount), 0), coalesce(sum(amount_to), 0)
+			FROM
+				transaction_history
+			WHERE
+				reversal_of = $1
+		`, orig.ID).Scan(&reversedFrom, &reversedTo)
+		if err != nil {
+			return err
+		}
+
+		reversed := reversedFrom
+		if orig.FX != nil {
+			reversed = reversedTo
+		}
+
+		remaining := orig.Amount - reversed
+
+		if h.Amount == 0 {
+			h.Amount = remaining
+		}
+
+		if h.Amount <= 0 || h.Amount > remaining {
+			return ErrReversalExceedsAmount
+		}
+
+		h.IDFrom = orig.IDTo
+		h.IDTo = orig.IDFrom
+		h.Currency = orig.Currency
+
+		if orig.FX != nil {
+			if err := reverseFX(h, orig, h.Amount == remaining, reversedFrom); err != nil {
+				return err
+			}
+		}
+
+		accounts, err := db.lockUserAccountsInTx(ctx, tx, h.IDFrom, h.IDTo)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range []int{h.IDFrom, h.IDTo} {
+			a, ok := accounts[id]
+			if !ok {
+				// System account.
+				continue
+			}
+
+			if err := checkAccountStatus(a, id == h.IDFrom); err != nil {
+				return err
+			}
+		}
+
+		if err := db.changeBalanceInTx(ctx, tx, h.IDFrom, h.Currency, -h.Amount); err != nil {
+			return err
+		}
+
+		amountTo, currencyTo := h.ReceivedAmount()
+
+		if err := db.changeBalanceInTx(ctx, tx, h.IDTo, currencyTo, amountTo); err != nil {
+			return err
+		}
+
+		if err := db.CreateHistoryLog(ctx, tx, h); err != nil {
+			return err
+		}
+
+		if key != nil {
+			key.TransactionID = h.ID
+			return db.SaveIdempotencyResultInTx(ctx, tx, key)
+		}
+
+		return nil
+	})
+}
+
+// reverseFX converts reversal h of cross-currency transaction orig by original rate.
+// Last reversal takes from receiver exactly what is left of orig.FX.AmountTo, so rounding doesn't accumulate.
+func reverseFX(h *model.TransactionHistory, orig *model.TransactionHistory, last bool, reversedFrom money.Amount) error {
+	rate, ok := new(big.Rat).SetString(orig.FX.Rate)
+	if !ok || rate.Sign() <= 0 {
+		return fmt.Errorf("invalid rate %q of transaction #%d", orig.FX.Rate, orig.ID)
+	}
+
+	amountBack := h.Amount
+	amountTaken := orig.FX.AmountTo - reversedFrom
+
+	if !last {
+		var err error
+
+		amountTaken, err = money.FromRat(new(big.Rat).Mul(amountBack.Rat(), rate))
+		if err != nil {
+			return err
+		}
+	}
+
+	if amountTaken <= 0 {
+		return ErrAmountTooSmall
+	}
+
+	h.Amount = amountTaken
+	h.Currency = orig.FX.CurrencyTo
+	h.FX = &model.FXConversion{
+		AmountTo:   amountBack,
+		CurrencyTo: orig.Currency,
+		Rate:       new(big.Rat).Inv(rate).FloatString(convertor.RateScale),
+		RateAt:     orig.FX.RateAt,
+	}
+
+	return nil
+}
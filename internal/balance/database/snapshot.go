@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// walletBalanceAt is a balance of wallet w at $2: the latest snapshot taken not after $2
+// plus postings created between snapshot and $2.
+const walletBalanceAt = `
+	coalesce(s.balance, 0) + coalesce((
+		SELECT
+			sum(p.amount)
+		FROM
+			postings p
+		WHERE
+			p.account_id = w.account_id AND p.currency = w.currency AND
+			p.created_at >= coalesce(s.taken_at, '-infinity') AND p.created_at < $2
+	), 0)
+`
+
+// latestSnapshot joins the latest snapshot of wallet w taken not after $2.
+const latestSnapshot = `
+	LEFT JOIN LATERAL (
+		SELECT
+			bs.balance, bs.taken_at
+		FROM
+			balance_snapshots bs
+		WHERE
+			bs.account_id = w.account_id AND bs.currency = w.currency AND bs.taken_at <= $2
+		ORDER BY bs.taken_at DESC
+		LIMIT 1
+	) s ON true
+`
+
+// GetBalanceAccountAt returns account with balances of its wallets at t. Holds history isn't kept,
+// so held amount of wallets is zero.
+func (db *BalanceDB) GetBalanceAccountAt(ctx context.Context, id int, t time.Time) (*model.Account, error) {
+	var a *model.Account
+
+	err := db.db.InTx(ctx, pgx.RepeatableRead, func(tx pgx.Tx) error {
+		var err error
+
+		a, err = scanAccount(tx.QueryRow(ctx, `
+			SELECT
+				`+accountColumns+`
+			FROM
+				accounts
+			WHERE
+				id = $1 AND type = $2
+		`, id, model.AccountTypeUser))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAccountNotFound
+			}
+
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				w.account_id, w.currency, `+walletBalanceAt+`
+			FROM
+				wallets w
+				`+latestSnapshot+`
+			WHERE
+				w.account_id = $1
+			ORDER BY w.currency
+		`, id, t)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var w model.Wallet
+
+			if err := rows.Scan(&w.AccountID, &w.Currency, &w.Balance); err != nil {
+				return err
+			}
+
+			a.Wallets = append(a.Wallets, &w)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// CreateBalanceSnapshots saves balances of all user wallets at t and returns count of created snapshots.
+// Transactions created before t must be already committed.
+func (db *BalanceDB) CreateBalanceSnapshots(ctx context.Context, t time.Time) (int, error) {
+	var count int
+
+	err := db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		r, err := tx.Exec(ctx, `
+			INSERT INTO
+				balance_snapshots
+				(account_id, currency, balance, taken_at)
+			SELECT
+				w.account_id, w.currency, `+walletBalanceAt+`, $2
+			FROM
+				wallets w
+				JOIN accounts a ON a.id = w.account_id AND a.type = $1
+				`+latestSnapshot+`
+			ON CONFLICT DO NOTHING
+		`, model.AccountTypeUser, t)
+		if err != nil {
+			return err
+		}
+
+		count = int(r.RowsAffected())
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
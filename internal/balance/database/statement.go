@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+// GetStatement returns statement of account wallet in currency for period [from, to).
+// Balances are computed back from current wallet balance, all queries read the same snapshot.
+func (db *BalanceDB) GetStatement(ctx context.Context, id int, currency string, from time.Time, to time.Time) (*model.Statement, error) {
+	var st *model.Statement
+
+	err := db.db.InTx(ctx, pgx.RepeatableRead, func(tx pgx.Tx) error {
+		_, err := scanAccount(tx.QueryRow(ctx, `
+			SELECT
+				`+accountColumns+`
+			FROM
+				accounts
+			WHERE
+				id = $1 AND type = $2
+		`, id, model.AccountTypeUser))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrAccountNotFound
+			}
+
+			return err
+		}
+
+		var current, afterPeriod money.Amount
+
+		err = tx.QueryRow(ctx, `
+			SELECT
+				coalesce((SELECT balance FROM wallets WHERE account_id = $1 AND currency = $2), 0),
+				coalesce((SELECT sum(amount) FROM postings WHERE account_id = $1 AND currency = $2 AND created_at >= $3), 0)
+		`, id, currency, to).Scan(&current, &afterPeriod)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				`+historyColumns+`, p.amount
+			FROM
+				postings p
+				JOIN transaction_history th ON th.id = p.transaction_id
+			WHERE
+				p.account_id = $1 AND p.currency = $2 AND p.created_at >= $3 AND p.created_at < $4
+			ORDER BY p.created_at, p.id
+		`, id, currency, from, to)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		type line struct {
+			th     *model.TransactionHistory
+			amount money.Amount
+		}
+
+		var lines []line
+		var inPeriod money.Amount
+
+		for rows.Next() {
+			var l line
+
+			l.th, err = scanHistory(rows, &l.amount)
+			if err != nil {
+				return err
+			}
+
+			inPeriod += l.amount
+			lines = append(lines, l)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		st = &model.Statement{
+			AccountID:      id,
+			Currency:       currency,
+			From:           from,
+			To:             to,
+			ClosingBalance: current - afterPeriod,
+			OpeningBalance: current - afterPeriod - inPeriod,
+		}
+
+		for _, l := range lines {
+			st.AddLine(l.th, l.amount)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
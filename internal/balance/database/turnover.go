@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/jackc/pgx/v4"
+	"sort"
+	"time"
+)
+
+// turnoverColumns aggregate postings p to incoming, outgoing and count of transactions.
+const turnoverColumns = `
+	coalesce(sum(p.amount) FILTER (WHERE p.amount > 0), 0),
+	coalesce(-sum(p.amount) FILTER (WHERE p.amount < 0), 0),
+	count(DISTINCT p.transaction_id)
+`
+
+// GetTurnover returns turnover of account, or of all user accounts for model.TurnoverAllAccounts,
+// in [from, to) grouped by period and currency, and count of transactions in all currencies grouped by period.
+// Whole days already in rollup are read from it, the rest is aggregated from postings.
+func (db *BalanceDB) GetTurnover(ctx context.Context, id int, period string, from time.Time, to time.Time) ([]*model.Turnover, []*model.TurnoverCount, error) {
+	var turnover map[string]*model.Turnover
+	var counts map[string]*model.TurnoverCount
+
+	add := func(t *model.Turnover) {
+		key := t.Period.String() + t.Currency
+
+		if existing, ok := turnover[key]; ok {
+			existing.Add(t)
+		} else {
+			turnover[key] = t
+		}
+	}
+
+	addCount := func(c *model.TurnoverCount) {
+		key := c.Period.String()
+
+		if existing, ok := counts[key]; ok {
+			existing.Count += c.Count
+		} else {
+			counts[key] = c
+		}
+	}
+
+	err := db.db.InTx(ctx, pgx.RepeatableRead, func(tx pgx.Tx) error {
+		turnover = map[string]*model.Turnover{}
+		counts = map[string]*model.TurnoverCount{}
+
+		var refreshedUntil *time.Time
+
+		if err := tx.QueryRow(ctx, `SELECT refreshed_until FROM turnover_rollup_state`).Scan(&refreshedUntil); err != nil {
+			return err
+		}
+
+		if refreshedUntil != nil {
+			t := asLocal(*refreshedUntil)
+			refreshedUntil = &t
+		}
+
+		// Rollup is used only for whole days of [from, to) which it already contains.
+		rollupFrom, rollupTo := startOfDay(from), startOfDay(to)
+		if !rollupFrom.Equal(from) {
+			rollupFrom = rollupFrom.AddDate(0, 0, 1)
+		}
+
+		if refreshedUntil == nil {
+			rollupTo = rollupFrom
+		} else if refreshedUntil.Before(rollupTo) {
+			rollupTo = *refreshedUntil
+		}
+
+		ranges := [][2]time.Time{{from, to}}
+
+		if rollupFrom.Before(rollupTo) {
+			if err := queryTurnover(ctx, tx, add, `
+				SELECT
+					date_trunc($1, r.day), r.currency, sum(r.incoming), sum(r.outgoing), sum(r.count)
+				FROM
+					turnover_rollup r
+				WHERE
+					r.account_id = $2 AND r.day >= $3 AND r.day < $4
+				GROUP BY 1, 2
+			`, period, id, rollupFrom, rollupTo); err != nil {
+				return err
+			}
+
+			if err := queryTurnoverCounts(ctx, tx, addCount, `
+				SELECT
+					date_trunc($1, r.day), sum(r.count)
+				FROM
+					turnover_rollup_counts r
+				WHERE
+					r.account_id = $2 AND r.day >= $3 AND r.day < $4
+				GROUP BY 1
+			`, period, id, rollupFrom, rollupTo); err != nil {
+				return err
+			}
+
+			ranges = [][2]time.Time{{from, rollupFrom}, {rollupTo, to}}
+		}
+
+		account := "p.account_id = $2"
+		if id == model.TurnoverAllAccounts {
+			// $2 is still compared, because type of every param must be known.
+			account = "p.account_id > 0 AND $2 = 0"
+		}
+
+		for _, r := range ranges {
+			if !r[0].Before(r[1]) {
+				continue
+			}
+
+			if err := queryTurnover(ctx, tx, add, fmt.Sprintf(`
+				SELECT
+					date_trunc($1, p.created_at), p.currency, `+turnoverColumns+`
+				FROM
+					postings p
+				WHERE
+					%s AND p.created_at >= $3 AND p.created_at < $4
+				GROUP BY 1, 2
+			`, account), period, id, r[0], r[1]); err != nil {
+				return err
+			}
+
+			if err := queryTurnoverCounts(ctx, tx, addCount, fmt.Sprintf(`
+				SELECT
+					date_trunc($1, p.created_at), count(DISTINCT p.transaction_id)
+				FROM
+					postings p
+				WHERE
+					%s AND p.created_at >= $3 AND p.created_at < $4
+				GROUP BY 1
+			`, account), period, id, r[0], r[1]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]*model.Turnover, 0, len(turnover))
+	for _, t := range turnover {
+		result = append(result, t)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Period.Equal(result[j].Period) {
+			return result[i].Period.Before(result[j].Period)
+		}
+
+		return result[i].Currency < result[j].Currency
+	})
+
+	countResult := make([]*model.TurnoverCount, 0, len(counts))
+	for _, c := range counts {
+		countResult = append(countResult, c)
+	}
+
+	sort.Slice(countResult, func(i, j int) bool {
+		return countResult[i].Period.Before(countResult[j].Period)
+	})
+
+	return result, countResult, nil
+}
+
+func queryTurnover(ctx context.Context, tx pgx.Tx, add func(t *model.Turnover), query string, args ...interface{}) error {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t model.Turnover
+
+		if err := rows.Scan(&t.Period, &t.Currency, &t.Incoming, &t.Outgoing, &t.Count); err != nil {
+			return err
+		}
+
+		add(&t)
+	}
+
+	return rows.Err()
+}
+
+func queryTurnoverCounts(ctx context.Context, tx pgx.Tx, add func(c *model.TurnoverCount), query string, args ...interface{}) error {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c model.TurnoverCount
+
+		if err := rows.Scan(&c.Period, &c.Count); err != nil {
+			return err
+		}
+
+		add(&c)
+	}
+
+	return rows.Err()
+}
+
+// RefreshTurnoverRollup adds to rollup all days from last refresh to the day of until exclusive.
+// Postings created before until must be already committed.
+func (db *BalanceDB) RefreshTurnoverRollup(ctx context.Context, until time.Time) error {
+	until = startOfDay(until)
+
+	return db.db.InTx(ctx, pgx.ReadCommitted, func(tx pgx.Tx) error {
+		var refreshedUntil *time.Time
+
+		// Lock serializes concurrent refreshes.
+		err := tx.QueryRow(ctx, `SELECT refreshed_until FROM turnover_rollup_state FOR UPDATE`).Scan(&refreshedUntil)
+		if err != nil {
+			return err
+		}
+
+		if refreshedUntil != nil && !asLocal(*refreshedUntil).Before(until) {
+			return nil
+		}
+
+		// First refresh starts from the beginning of history.
+		var since interface{}
+		if refreshedUntil != nil {
+			since = *refreshedUntil
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO
+				turnover_rollup
+				(day, account_id, currency, incoming, outgoing, count)
+			SELECT
+				date_trunc('day', p.created_at), p.account_id, p.currency, `+turnoverColumns+`
+			FROM
+				postings p
+			WHERE
+				p.account_id > 0 AND ($1::timestamp IS NULL OR p.created_at >= $1) AND p.created_at < $2
+			GROUP BY 1, 2, 3
+			UNION ALL
+			SELECT
+				date_trunc('day', p.created_at), $3, p.currency, `+turnoverColumns+`
+			FROM
+				postings p
+			WHERE
+				p.account_id > 0 AND ($1::timestamp IS NULL OR p.created_at >= $1) AND p.created_at < $2
+			GROUP BY 1, 3
+			ON CONFLICT (account_id, day, currency) DO UPDATE SET
+				incoming = EXCLUDED.incoming,
+				outgoing = EXCLUDED.outgoing,
+				count = EXCLUDED.count
+		`, since, until, model.TurnoverAllAccounts)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO
+				turnover_rollup_counts
+				(day, account_id, count)
+			SELECT
+				date_trunc('day', p.created_at), p.account_id, count(DISTINCT p.transaction_id)
+			FROM
+				postings p
+			WHERE
+				p.account_id > 0 AND ($1::timestamp IS NULL OR p.created_at >= $1) AND p.created_at < $2
+			GROUP BY 1, 2
+			UNION ALL
+			SELECT
+				date_trunc('day', p.created_at), $3, count(DISTINCT p.transaction_id)
+			FROM
+				postings p
+			WHERE
+				p.account_id > 0 AND ($1::timestamp IS NULL OR p.created_at >= $1) AND p.created_at < $2
+			GROUP BY 1
+			ON CONFLICT (account_id, day) DO UPDATE SET
+				count = EXCLUDED.count
+		`, since, until, model.TurnoverAllAccounts)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE turnover_rollup_state SET refreshed_until = $1`, until)
+		return err
+	})
+}
+
+// asLocal returns scanned timestamp in local time of service, which it is stored in.
+func asLocal(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+// startOfDay returns midnight of day of t in its location.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
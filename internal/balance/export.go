@@ -0,0 +1,248 @@
+package balance
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// exportFlushEvery is a count of rows after which exported data is sent to client.
+const exportFlushEvery = 100
+
+// historyEncoder writes transactions of exported history.
+type historyEncoder interface {
+	Encode(t *v1.Transaction) error
+	// Flush writes buffered data to underlying writer.
+	Flush() error
+}
+
+// ExportHistory GET /api/balance/history/export
+func (s *Service) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		if err := s.validateCurrency(currency); err != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+			return
+		}
+	}
+
+	tc, err := s.newTransactionConvertor(r.URL.Query(), currency)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	sortBy, sortOrder, err := parseHistorySort(r.URL.Query())
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	filter, err := parseHistoryFilter(r.URL.Query(), id)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	var enc historyEncoder
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		enc = newCSVHistoryEncoder(w)
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = &ndjsonHistoryEncoder{enc: json.NewEncoder(w)}
+	default:
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Format param must be csv or ndjson"))
+		return
+	}
+
+	// Rates are loaded before cursor is opened, so slow provider doesn't hold its transaction.
+	if err := tc.prefetch(ctx, filter); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, "Cannot get historical rates: "+err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="history-%d.%s"`, id, format))
+
+	// Without Content-Length response is sent with chunked transfer encoding.
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	err = s.db.StreamHistory(ctx, filter, sortBy, sortOrder, func(th *model.TransactionHistory) error {
+		t, err := s.newTransactionResponse(ctx, th, tc)
+		if err != nil {
+			return err
+		}
+
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+
+		written++
+
+		if written%exportFlushEvery == 0 {
+			return flush(enc, flusher)
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		err = flush(enc, flusher)
+	}
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			// Client has gone away.
+			return
+		}
+
+		if written == 0 {
+			w.Header().Del("Content-Disposition")
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot export history data"))
+			return
+		}
+
+		log.Printf("failed to export history of account #%d: %s", id, err)
+		abortResponse(w)
+	}
+}
+
+// abortResponse closes connection, so client sees broken chunked body instead of successfully finished one.
+// It is used when status is already sent.
+func abortResponse(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return
+	}
+
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+
+	conn.Close()
+}
+
+func flush(enc historyEncoder, flusher http.Flusher) error {
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// ndjsonHistoryEncoder writes transactions as JSON objects separated by newline.
+type ndjsonHistoryEncoder struct {
+	enc *json.Encoder
+}
+
+// Encode implements historyEncoder.
+func (e *ndjsonHistoryEncoder) Encode(t *v1.Transaction) error {
+	return e.enc.Encode(t)
+}
+
+// Flush implements historyEncoder. Encoder isn't buffered.
+func (e *ndjsonHistoryEncoder) Flush() error {
+	return nil
+}
+
+// csvHistoryEncoder writes transactions as CSV rows with header.
+type csvHistoryEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVHistoryEncoder(w io.Writer) *csvHistoryEncoder {
+	return &csvHistoryEncoder{w: csv.NewWriter(w)}
+}
+
+// Encode implements historyEncoder.
+func (e *csvHistoryEncoder) Encode(t *v1.Transaction) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	var amountTo, fxRateAt, reversalOf string
+
+	if t.AmountTo != nil {
+		amountTo = t.AmountTo.String()
+	}
+
+	if t.FXRateAt != nil {
+		fxRateAt = t.FXRateAt.Format(time.RFC3339Nano)
+	}
+
+	if t.ReversalOf != nil {
+		reversalOf = strconv.FormatInt(*t.ReversalOf, 10)
+	}
+
+	return e.w.Write([]string{
+		strconv.FormatInt(t.ID, 10),
+		strconv.Itoa(t.IDFrom),
+		strconv.Itoa(t.IDTo),
+		t.Amount.String(),
+		t.Currency,
+		amountTo,
+		t.CurrencyTo,
+		t.FXRate,
+		fxRateAt,
+		t.Comment,
+		reversalOf,
+		t.CreatedAt.Format(time.RFC3339Nano),
+		t.RateDate,
+		string(t.Metadata),
+	})
+}
+
+// Flush implements historyEncoder. Header is written even if there are no transactions.
+func (e *csvHistoryEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvHistoryEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+
+	e.headerWritten = true
+
+	return e.w.Write([]string{
+		"id", "id_from", "id_to", "amount", "currency", "amount_to", "currency_to",
+		"fx_rate", "fx_rate_at", "comment", "reversal_of", "created_at", "rate_date", "metadata",
+	})
+}
@@ -1,13 +1,20 @@
 package balance
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
 	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // TransactionsHistory GET /api/balance/history
@@ -21,8 +28,17 @@ func (s *Service) TransactionsHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currency := r.URL.Query().Get("currency")
-	if currency == "" {
-		currency = "RUB"
+	if currency != "" {
+		if err := s.validateCurrency(currency); err != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+			return
+		}
+	}
+
+	tc, err := s.newTransactionConvertor(r.URL.Query(), currency)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
 	}
 
 	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
@@ -35,69 +51,229 @@ func (s *Service) TransactionsHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if limit <= 0 {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Limit must be > 0"))
+		return
+	}
+
 	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
 	if err != nil {
 		offset = 0
 	}
 
-	sortBy := r.URL.Query().Get("sort_by")
-	if sortBy == "" {
-		sortBy = "created_at"
+	sortBy, sortOrder, err := parseHistorySort(r.URL.Query())
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
 	}
 
-	if sortBy != "created_at" && sortBy != "amount" {
-		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "SortBy param must be created_at or amount"))
+	filter, err := parseHistoryFilter(r.URL.Query(), id)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
 		return
 	}
 
-	sortOrder := r.URL.Query().Get("sort_order")
+	var response v1.GetHistoryResponse
+	var history []*model.TransactionHistory
+
+	cursor := r.URL.Query().Get("cursor")
+
+	if cursor != "" || r.URL.Query().Get("pagination") == "cursor" {
+		if sortBy == model.HistorySortByRelevance {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Cursor pagination doesn't support sort by relevance"))
+			return
+		}
+
+		var after *model.HistoryCursor
+
+		if cursor != "" {
+			after, err = decodeHistoryCursor(cursor)
+			if err != nil || after.SortBy != sortBy || after.SortOrder != sortOrder {
+				jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Cursor is invalid or doesn't match sort params"))
+				return
+			}
+		}
+
+		// One extra row shows whether there is next page.
+		history, err = s.db.GetHistoryPage(ctx, filter, limit+1, sortBy, sortOrder, after)
+		if err != nil {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get history data"))
+			return
+		}
+
+		if len(history) > limit {
+			history = history[:limit]
+			response.NextCursor = encodeHistoryCursor(history[limit-1].Cursor(sortBy, sortOrder))
+		}
+
+		response.History = []*v1.Transaction{}
+	} else {
+		var count int
+
+		history, count, err = s.db.GetHistory(ctx, filter, limit, offset, sortBy, sortOrder)
+		if err != nil {
+			if errors.Is(err, balanceDB.ErrAccountNotFound) {
+				jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(2, "Account not found"))
+			} else {
+				jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get history data"))
+			}
+
+			return
+		}
+
+		response.Count = &count
+	}
+
+	for _, v := range history {
+		t, err := s.newTransactionResponse(ctx, v, tc)
+		if err != nil {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, err.Error()))
+			return
+		}
+
+		response.History = append(response.History, t)
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, response)
+}
+
+// parseHistorySort returns validated sort column and upper-case sort order from query params.
+// Search results are sorted by relevance by default.
+func parseHistorySort(query url.Values) (string, string, error) {
+	search := strings.TrimSpace(query.Get("q")) != ""
+
+	sortBy := query.Get("sort_by")
+	if sortBy == "" {
+		sortBy = model.HistorySortByCreatedAt
+		if search {
+			sortBy = model.HistorySortByRelevance
+		}
+	}
+
+	switch sortBy {
+	case model.HistorySortByCreatedAt, model.HistorySortByAmount:
+	case model.HistorySortByRelevance:
+		if !search {
+			return "", "", errors.New("SortBy param can be relevance only with Q param")
+		}
+	default:
+		return "", "", errors.New("SortBy param must be created_at, amount or relevance")
+	}
+
+	sortOrder := query.Get("sort_order")
 	if sortOrder == "" {
 		sortOrder = "DESC"
 	}
 
 	if strings.ToTitle(sortOrder) != "DESC" && strings.ToTitle(sortOrder) != "ASC" {
-		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "SortOrder param must be DESC or ASC"))
-		return
+		return "", "", errors.New("SortOrder param must be DESC or ASC")
 	}
 
-	history, count, err := s.db.GetHistory(ctx, id, limit, offset, sortBy, strings.ToTitle(sortOrder))
-	if err != nil {
-		if errors.Is(err, balanceDB.ErrAccountNotFound) {
-			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(2, "Account not found"))
-		} else {
-			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get history data"))
+	return sortBy, strings.ToTitle(sortOrder), nil
+}
+
+// parseHistoryFilter returns filter of history of account id from query params.
+func parseHistoryFilter(query url.Values, id int) (*model.HistoryFilter, error) {
+	f := model.HistoryFilter{
+		AccountID: id,
+		Direction: query.Get("direction"),
+		Comment:   query.Get("comment"),
+		Search:    strings.TrimSpace(query.Get("q")),
+	}
+
+	for _, v := range []struct {
+		param string
+		name  string
+		dest  **time.Time
+	}{
+		{"from", "From", &f.From},
+		{"to", "To", &f.To},
+	} {
+		if s := query.Get(v.param); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("%s param must be RFC 3339 time", v.name)
+			}
+
+			t = t.Local()
+			*v.dest = &t
 		}
+	}
 
-		return
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return nil, errors.New("From param must be before To param")
 	}
 
-	response := v1.GetHistoryResponse{
-		Count: count,
+	switch f.Direction {
+	case "", model.HistoryDirectionIncoming, model.HistoryDirectionOutgoing, model.HistoryDirectionSystem:
+	default:
+		return nil, errors.New("Direction param must be incoming, outgoing or system")
 	}
 
-	for _, v := range history {
-		t := v1.Transaction{
-			IDFrom:    v.IDFrom,
-			IDTo:      v.IDTo,
-			Currency:  currency,
-			CreatedAt: v.CreatedAt,
-			Comment:   v.Comment,
+	if key := query.Get("metadata_key"); key != "" {
+		f.MetadataKey = key
+
+		if value := query.Get("metadata_value"); value != "" {
+			// Value which isn't valid JSON is a string.
+			f.MetadataValue = json.RawMessage(value)
+			if !json.Valid(f.MetadataValue) {
+				f.MetadataValue, _ = json.Marshal(value)
+			}
 		}
+	} else if query.Get("metadata_value") != "" {
+		return nil, errors.New("MetadataValue param can be set only with MetadataKey param")
+	}
 
-		if currency == "RUB" {
-			t.Amount = v.Amount
-		} else {
-			c, err := s.cConvertor.Convert(v.Amount, currency)
-			if err != nil {
-				jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, err.Error()))
-				return
+	if s := query.Get("counterparty_id"); s != "" {
+		counterpartyID, err := strconv.Atoi(s)
+		if err != nil || counterpartyID == 0 {
+			return nil, errors.New("CounterpartyID param must be non-zero integer")
+		}
+
+		f.CounterpartyID = counterpartyID
+	}
+
+	for _, v := range []struct {
+		param string
+		name  string
+		dest  **money.Amount
+	}{
+		{"min_amount", "MinAmount", &f.MinAmount},
+		{"max_amount", "MaxAmount", &f.MaxAmount},
+	} {
+		if s := query.Get(v.param); s != "" {
+			a, err := money.Parse(s)
+			if err != nil || a < 0 {
+				return nil, fmt.Errorf("%s param must be not negative amount", v.name)
 			}
 
-			t.Amount = c
+			*v.dest = &a
 		}
+	}
 
-		response.History = append(response.History, &t)
+	if f.MinAmount != nil && f.MaxAmount != nil && *f.MinAmount > *f.MaxAmount {
+		return nil, errors.New("MinAmount param must be <= MaxAmount param")
 	}
 
-	jsonutil.MarshalResponse(w, http.StatusOK, response)
+	return &f, nil
+}
+
+// encodeHistoryCursor returns opaque string representation of cursor.
+func encodeHistoryCursor(c *model.HistoryCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeHistoryCursor(s string) (*model.HistoryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var c model.HistoryCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
 }
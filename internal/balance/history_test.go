@@ -0,0 +1,50 @@
+package balance
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Unknown currency is rejected before history or transaction is read.
+func TestUnknownCurrency(t *testing.T) {
+	s := &Service{
+		cConvertor: convertor.NewCurrencyConvertor(map[string]float64{"RUB": 1, "USD": 0.0137}, time.Now()),
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("transactionID", "1")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+	}{
+		{"history", s.TransactionsHistory, "/api/balance/history?id=1&currency=XXX"},
+		{"export", s.ExportHistory, "/api/balance/history/export?id=1&currency=XXX"},
+		{"transaction", s.GetTransaction, "/api/balance/transactions/1?currency=XXX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+
+			if !strings.Contains(w.Body.String(), "currency XXX is not supported") {
+				t.Errorf("body = %s, want unsupported currency error", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/job"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type createHoldRequest struct {
+	Amount    money.Amount `json:"amount"`
+	Currency  string       `json:"currency"`
+	Comment   string       `json:"comment"`
+	ExpiresAt *time.Time   `json:"expires_at"`
+}
+
+func (r *createHoldRequest) validate() error {
+	if r.Amount <= 0 {
+		return errors.New("amount must be > 0")
+	}
+
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return errors.New("expires_at must be in the future")
+	}
+
+	if r.Currency == "" {
+		r.Currency = defaultCurrency
+	}
+
+	return nil
+}
+
+// CreateHold POST /api/balance/hold
+func (s *Service) CreateHold(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	var req createHoldRequest
+
+	unmarshallStatusCode, err := jsonutil.Unmarshal(w, r, &req)
+	if err != nil {
+		jsonutil.MarshalResponse(w, unmarshallStatusCode, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	if err := s.validateCurrency(req.Currency); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+		return
+	}
+
+	h := model.Hold{
+		AccountID: id,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Comment:   req.Comment,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	h.Prepare()
+
+	err = s.db.CreateHold(ctx, &h)
+	if err != nil {
+		if writeAccountStatusError(w, err) {
+			return
+		}
+
+		if errors.Is(err, balanceDB.ErrAccountNotFound) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Account not found"))
+			return
+		}
+
+		if errors.Is(err, balanceDB.ErrBalanceMustBePositive) {
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(5, "Not enough available balance"))
+			return
+		}
+
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Error while create hold"))
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(newHoldResponse(&h)))
+}
+
+// GetHold GET /api/balance/hold/{holdID}
+func (s *Service) GetHold(w http.ResponseWriter, r *http.Request) {
+	s.handleHold(w, r, s.db.GetHold, func(h *v1.Hold) interface{} {
+		return h
+	})
+}
+
+// CaptureHold POST /api/balance/hold/{holdID}/capture
+func (s *Service) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	s.handleHold(w, r, s.db.CaptureHold, func(h *v1.Hold) interface{} {
+		return jsonutil.NewSuccessfulResponse(h)
+	})
+}
+
+// ReleaseHold POST /api/balance/hold/{holdID}/release
+func (s *Service) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	s.handleHold(w, r, s.db.ReleaseHold, func(h *v1.Hold) interface{} {
+		return jsonutil.NewSuccessfulResponse(h)
+	})
+}
+
+func (s *Service) handleHold(w http.ResponseWriter, r *http.Request, f func(ctx context.Context, id int64) (*model.Hold, error), wrap func(h *v1.Hold) interface{}) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "holdID"), 10, 64)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	h, err := f(ctx, id)
+	if err != nil {
+		if writeAccountStatusError(w, err) {
+			return
+		}
+
+		if errors.Is(err, balanceDB.ErrHoldNotFound) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(9, "Hold not found"))
+			return
+		}
+
+		if errors.Is(err, balanceDB.ErrHoldNotActive) {
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(10, "Hold is already captured, released or expired"))
+			return
+		}
+
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Error while process hold"))
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, wrap(newHoldResponse(h)))
+}
+
+// RunHoldSweeper releases expired holds every interval until ctx is done.
+func (s *Service) RunHoldSweeper(ctx context.Context, interval time.Duration) {
+	job.Every(ctx, interval, func(ctx context.Context) {
+		for {
+			count, err := s.db.ReleaseExpiredHolds(ctx, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("failed to release expired holds: %s", err)
+				}
+
+				break
+			}
+
+			if count == 0 {
+				break
+			}
+
+			log.Printf("released %d expired holds", count)
+		}
+	})
+}
+
+func newHoldResponse(h *model.Hold) *v1.Hold {
+	return &v1.Hold{
+		ID:            h.ID,
+		AccountID:     h.AccountID,
+		Amount:        h.Amount,
+		Currency:      h.Currency,
+		Status:        h.Status,
+		Comment:       h.Comment,
+		TransactionID: h.TransactionID,
+		CreatedAt:     h.CreatedAt,
+		ExpiresAt:     h.ExpiresAt,
+		UpdatedAt:     h.UpdatedAt,
+	}
+}
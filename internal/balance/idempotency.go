@@ -0,0 +1,76 @@
+package balance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/job"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// newIdempotencyKey returns nil if request has no Idempotency-Key header.
+// Request hash covers method, path, query and decoded body.
+func (s *Service) newIdempotencyKey(r *http.Request, body interface{}) (*model.IdempotencyKey, error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, errors.New("idempotency key must be <= 255 characters")
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.Query().Encode() + "\n"))
+	h.Write(b)
+
+	k := model.IdempotencyKey{
+		Key:         key,
+		RequestHash: hex.EncodeToString(h.Sum(nil)),
+	}
+
+	k.Prepare(s.idempotencyKeyTTL)
+
+	return &k, nil
+}
+
+// markIdempotentReplay sets response header if request was already done with the same key.
+func markIdempotentReplay(w http.ResponseWriter, key *model.IdempotencyKey) {
+	if key != nil && key.Replayed {
+		w.Header().Set(idempotencyReplayedHeader, "true")
+	}
+}
+
+// RunIdempotencyKeyPurger deletes expired idempotency keys every interval until ctx is done.
+func (s *Service) RunIdempotencyKeyPurger(ctx context.Context, interval time.Duration) {
+	job.Every(ctx, interval, func(ctx context.Context) {
+		count, err := s.db.PurgeExpiredIdempotencyKeys(ctx, time.Now())
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to purge expired idempotency keys: %s", err)
+			}
+
+			return
+		}
+
+		if count > 0 {
+			log.Printf("purged %d expired idempotency keys", count)
+		}
+	})
+}
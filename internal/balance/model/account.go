@@ -1,7 +1,49 @@
 package model
 
+import "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+
+const (
+	// SystemSourceAccountID is a system ledger account, money come from it on balance top up.
+	SystemSourceAccountID = -1
+	// SystemSinkAccountID is a system ledger account, money go to it on balance withdrawal.
+	SystemSinkAccountID = -2
+	// SystemFXAccountID is a system ledger account which exchanges currencies in cross-currency transfers.
+	SystemFXAccountID = -3
+)
+
+// Account types.
+const (
+	AccountTypeUser   = "user"
+	AccountTypeSystem = "system"
+)
+
+// Account statuses.
+const (
+	// AccountStatusActive account accepts all operations.
+	AccountStatusActive = "active"
+	// AccountStatusFrozen account rejects debits and transfers out.
+	AccountStatusFrozen = "frozen"
+	// AccountStatusClosed account rejects all operations.
+	AccountStatusClosed = "closed"
+)
+
 // Account struct.
 type Account struct {
 	ID      int
-	Balance float64
+	Type    string
+	Status  string
+	Wallets []*Wallet
+}
+
+// Wallet is a balance of account in one currency.
+type Wallet struct {
+	AccountID int
+	Currency  string
+	Balance   money.Amount
+	Held      money.Amount
+}
+
+// Available returns amount which is not reserved by holds.
+func (m *Wallet) Available() money.Amount {
+	return m.Balance - m.Held
 }
@@ -1,17 +1,150 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
 
 // TransactionHistory struct.
 type TransactionHistory struct {
-	IDFrom    int
-	IDTo      int
-	Amount    float64
-	Comment   string
+	ID       int64
+	IDFrom   int
+	IDTo     int
+	Amount   money.Amount
+	Currency string
+	FX       *FXConversion
+	Comment  string
+	// Metadata is an optional JSON object set by caller.
+	Metadata   json.RawMessage
+	ReversalOf *int64
+	CreatedAt  time.Time
+}
+
+// FXConversion is a currency exchange made in cross-currency transaction.
+type FXConversion struct {
+	AmountTo   money.Amount
+	CurrencyTo string
+	// Rate is a count of CurrencyTo units for one unit of transaction currency.
+	Rate   string
+	RateAt time.Time
+}
+
+// Directions of transaction relative to account.
+const (
+	// HistoryDirectionIncoming is a transfer from another user account.
+	HistoryDirectionIncoming = "incoming"
+	// HistoryDirectionOutgoing is a transfer to another user account.
+	HistoryDirectionOutgoing = "outgoing"
+	// HistoryDirectionSystem is a top up, withdrawal or hold capture made with system account.
+	HistoryDirectionSystem = "system"
+)
+
+// Columns of history sort.
+const (
+	HistorySortByCreatedAt = "created_at"
+	HistorySortByAmount    = "amount"
+	// HistorySortByRelevance is a rank of full-text search, it is used only with search query.
+	HistorySortByRelevance = "relevance"
+)
+
+// HistoryFilter selects transactions from history of account. Zero fields don't filter.
+type HistoryFilter struct {
+	AccountID int
+	// From is inclusive and To is exclusive bound of creation time.
+	From           *time.Time
+	To             *time.Time
+	Direction      string
+	CounterpartyID int
+	// MinAmount and MaxAmount are inclusive bounds of amount in transaction currency.
+	MinAmount *money.Amount
+	MaxAmount *money.Amount
+	// Comment is a case-insensitive substring of comment.
+	Comment string
+	// Search is a full-text search query over comment in web search syntax.
+	Search string
+	// MetadataKey selects transactions which metadata has the key, with MetadataValue if it is set.
+	// MetadataValue is a JSON value.
+	MetadataKey   string
+	MetadataValue json.RawMessage
+}
+
+// IsEmpty reports whether filter selects whole history of account.
+func (f *HistoryFilter) IsEmpty() bool {
+	return f.From == nil && f.To == nil && f.Direction == "" && f.CounterpartyID == 0 &&
+		f.MinAmount == nil && f.MaxAmount == nil && f.Comment == "" && f.Search == "" && f.MetadataKey == ""
+}
+
+// HistoryCursor is a position in history sorted by SortBy in SortOrder with ID as tie-breaker.
+type HistoryCursor struct {
+	SortBy    string
+	SortOrder string
 	CreatedAt time.Time
+	Amount    money.Amount
+	ID        int64
+}
+
+// Cursor returns position of transaction in history sorted by sortBy in sortOrder.
+func (m *TransactionHistory) Cursor(sortBy string, sortOrder string) *HistoryCursor {
+	return &HistoryCursor{
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+		CreatedAt: m.CreatedAt,
+		Amount:    m.Amount,
+		ID:        m.ID,
+	}
 }
 
 // Prepare model to insert to DB.
 func (m *TransactionHistory) Prepare() {
 	m.CreatedAt = time.Now()
 }
+
+// ReceivedAmount returns amount and currency credited to receiver.
+func (m *TransactionHistory) ReceivedAmount() (money.Amount, string) {
+	if m.FX != nil {
+		return m.FX.AmountTo, m.FX.CurrencyTo
+	}
+
+	return m.Amount, m.Currency
+}
+
+// Postings returns balanced ledger lines of transaction: debit of sender and credit of receiver.
+// Cross-currency transaction is exchanged through system FX account, so every currency is balanced.
+func (m *TransactionHistory) Postings() []Posting {
+	amountTo, currencyTo := m.ReceivedAmount()
+
+	postings := []Posting{
+		{
+			TransactionID: m.ID,
+			AccountID:     m.IDFrom,
+			Amount:        -m.Amount,
+			Currency:      m.Currency,
+			CreatedAt:     m.CreatedAt,
+		},
+	}
+
+	if m.FX != nil {
+		postings = append(postings, Posting{
+			TransactionID: m.ID,
+			AccountID:     SystemFXAccountID,
+			Amount:        m.Amount,
+			Currency:      m.Currency,
+			CreatedAt:     m.CreatedAt,
+		}, Posting{
+			TransactionID: m.ID,
+			AccountID:     SystemFXAccountID,
+			Amount:        -amountTo,
+			Currency:      currencyTo,
+			CreatedAt:     m.CreatedAt,
+		})
+	}
+
+	return append(postings, Posting{
+		TransactionID: m.ID,
+		AccountID:     m.IDTo,
+		Amount:        amountTo,
+		Currency:      currencyTo,
+		CreatedAt:     m.CreatedAt,
+	})
+}
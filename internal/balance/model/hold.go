@@ -0,0 +1,45 @@
+package model
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// Hold statuses.
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+)
+
+// Hold is a reserve of money on account.
+type Hold struct {
+	ID            int64
+	AccountID     int
+	Amount        money.Amount
+	Currency      string
+	Status        string
+	Comment       string
+	TransactionID *int64
+	CreatedAt     time.Time
+	ExpiresAt     *time.Time
+	UpdatedAt     time.Time
+}
+
+// Prepare model to insert to DB.
+func (m *Hold) Prepare() {
+	m.Status = HoldStatusActive
+	m.CreatedAt = time.Now()
+	m.UpdatedAt = m.CreatedAt
+
+	// Timestamps are stored without time zone in local time of service.
+	if m.ExpiresAt != nil {
+		expiresAt := m.ExpiresAt.Local()
+		m.ExpiresAt = &expiresAt
+	}
+}
+
+// IsExpired reports whether active hold is expired at t.
+func (m *Hold) IsExpired(t time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(t)
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// IdempotencyKey struct.
+type IdempotencyKey struct {
+	Key           string
+	RequestHash   string
+	TransactionID int64
+	Replayed      bool
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+// Prepare model to insert to DB.
+func (m *IdempotencyKey) Prepare(ttl time.Duration) {
+	m.CreatedAt = time.Now()
+	m.ExpiresAt = m.CreatedAt.Add(ttl)
+}
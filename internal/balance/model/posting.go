@@ -0,0 +1,17 @@
+package model
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// Posting is a ledger line of transaction.
+// Positive amount is credit of account, negative amount is debit.
+type Posting struct {
+	ID            int64
+	TransactionID int64
+	AccountID     int
+	Amount        money.Amount
+	Currency      string
+	CreatedAt     time.Time
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// ReconciliationReport is a result of check of ledger consistency.
+type ReconciliationReport struct {
+	ID            int64
+	MismatchCount int
+	OrphanCount   int
+	// Mismatches and Orphans aren't loaded for list of reports.
+	Mismatches []*BalanceMismatch
+	Orphans    []*OrphanTransaction
+	CreatedAt  time.Time
+}
+
+// BalanceMismatch is a wallet which balance differs from net of account postings.
+type BalanceMismatch struct {
+	AccountID int
+	Currency  string
+	// Expected is a net of postings and Actual is a stored wallet balance.
+	Expected money.Amount
+	Actual   money.Amount
+}
+
+// OrphanTransaction is a transaction which points at account that doesn't exist.
+type OrphanTransaction struct {
+	TransactionID int64
+	AccountID     int
+}
+
+// Prepare model to insert to DB.
+func (m *ReconciliationReport) Prepare() {
+	m.CreatedAt = time.Now()
+}
+
+// IsClean reports whether no inconsistency was found.
+func (m *ReconciliationReport) IsClean() bool {
+	return m.MismatchCount == 0 && m.OrphanCount == 0
+}
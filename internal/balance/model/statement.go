@@ -0,0 +1,48 @@
+package model
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// Statement of account wallet for period [From, To).
+type Statement struct {
+	AccountID      int
+	Currency       string
+	From           time.Time
+	To             time.Time
+	OpeningBalance money.Amount
+	ClosingBalance money.Amount
+	TotalCredits   money.Amount
+	TotalDebits    money.Amount
+	Lines          []*StatementLine
+}
+
+// StatementLine is a change of wallet balance made by transaction.
+type StatementLine struct {
+	Transaction *TransactionHistory
+	// Amount is positive for credit and negative for debit.
+	Amount money.Amount
+	// Balance is a running balance after transaction.
+	Balance money.Amount
+}
+
+// AddLine appends line and updates running balance and totals of statement.
+func (m *Statement) AddLine(th *TransactionHistory, amount money.Amount) {
+	balance := m.OpeningBalance
+	if len(m.Lines) > 0 {
+		balance = m.Lines[len(m.Lines)-1].Balance
+	}
+
+	if amount > 0 {
+		m.TotalCredits += amount
+	} else {
+		m.TotalDebits -= amount
+	}
+
+	m.Lines = append(m.Lines, &StatementLine{
+		Transaction: th,
+		Amount:      amount,
+		Balance:     balance + amount,
+	})
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// Periods of turnover grouping, they are valid date_trunc fields.
+const (
+	TurnoverPeriodDay   = "day"
+	TurnoverPeriodWeek  = "week"
+	TurnoverPeriodMonth = "month"
+)
+
+// TurnoverAllAccounts is an account id of turnover of all user accounts.
+const TurnoverAllAccounts = 0
+
+// Turnover of wallets in Currency for period which starts at Period.
+type Turnover struct {
+	Period   time.Time
+	Currency string
+	Incoming money.Amount
+	Outgoing money.Amount
+	// Count is a count of transactions in Currency.
+	Count int
+}
+
+// TurnoverCount is a count of transactions in all currencies for period which starts at Period.
+type TurnoverCount struct {
+	Period time.Time
+	Count  int
+}
+
+// Net returns difference of incoming and outgoing amounts.
+func (m *Turnover) Net() money.Amount {
+	return m.Incoming - m.Outgoing
+}
+
+// Add adds amounts and count of o to turnover.
+func (m *Turnover) Add(o *Turnover) {
+	m.Incoming += o.Incoming
+	m.Outgoing += o.Outgoing
+	m.Count += o.Count
+}
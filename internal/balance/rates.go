@@ -0,0 +1,158 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"net/url"
+	"time"
+)
+
+// Modes of conversion of past transactions.
+const (
+	// rateModeCurrent converts by current rate of convertor.
+	rateModeCurrent = "current"
+	// rateModeHistorical converts by rate of date of transaction.
+	rateModeHistorical = "historical"
+)
+
+// rateDateLayout is a format of date of historical rate.
+const rateDateLayout = "2006-01-02"
+
+// transactionConvertor converts amount of transactions to currency by current or historical rates.
+type transactionConvertor struct {
+	s          *Service
+	currency   string
+	historical bool
+	// rates caches historical rates by date of transaction.
+	rates map[string]*historicalRates
+	// prefetched is set when all needed rates are loaded, missing rates aren't fetched then.
+	prefetched bool
+}
+
+// historicalRates are rates returned by provider for date of transaction.
+type historicalRates struct {
+	cc *convertor.CurrencyConvertor
+	// date of rates in YYYY-MM-DD format, it can be earlier than requested one.
+	date string
+}
+
+// newTransactionConvertor returns convertor to currency by rate_mode query param. Empty currency means no conversion.
+func (s *Service) newTransactionConvertor(query url.Values, currency string) (*transactionConvertor, error) {
+	c := transactionConvertor{
+		s:        s,
+		currency: currency,
+		rates:    map[string]*historicalRates{},
+	}
+
+	switch query.Get("rate_mode") {
+	case "", rateModeCurrent:
+	case rateModeHistorical:
+		if s.historicalRates == nil {
+			return nil, errors.New("historical rates are not supported by configured rate providers")
+		}
+
+		c.historical = true
+	default:
+		return nil, errors.New("RateMode param must be current or historical")
+	}
+
+	return &c, nil
+}
+
+// prefetch loads historical rates of all transactions of history selected by f, so convert doesn't fetch them.
+// It must be called before history is streamed, because rates can be fetched from provider slowly.
+func (c *transactionConvertor) prefetch(ctx context.Context, f *model.HistoryFilter) error {
+	if !c.historical || c.currency == "" {
+		return nil
+	}
+
+	dates, err := c.s.db.GetHistoryDates(ctx, f, c.currency)
+	if err != nil {
+		return err
+	}
+
+	// Transactions can be created until history is streamed.
+	dates = append(dates, time.Now().Format(rateDateLayout))
+
+	for _, date := range dates {
+		if _, err := c.historicalRates(ctx, date); err != nil {
+			return err
+		}
+	}
+
+	c.prefetched = true
+
+	return nil
+}
+
+// convert returns amount of transaction in currency of convertor and date of historical rate used for conversion.
+func (c *transactionConvertor) convert(ctx context.Context, th *model.TransactionHistory) (money.Amount, string, error) {
+	if c.currency == "" || c.currency == th.Currency {
+		return th.Amount, "", nil
+	}
+
+	if !c.historical {
+		a, err := c.s.cConvertor.Convert(th.Amount, th.Currency, c.currency)
+		return a, "", err
+	}
+
+	// Timestamps are stored in local time of service, so date is taken as is.
+	date := th.CreatedAt.Format(rateDateLayout)
+
+	hr, err := c.historicalRates(ctx, date)
+	if err != nil {
+		return 0, "", err
+	}
+
+	a, err := hr.cc.Convert(th.Amount, th.Currency, c.currency)
+	return a, hr.date, err
+}
+
+// historicalRates returns rates of date. Missing rates are fetched from provider and stored.
+func (c *transactionConvertor) historicalRates(ctx context.Context, date string) (*historicalRates, error) {
+	if hr, ok := c.rates[date]; ok {
+		return hr, nil
+	}
+
+	if c.prefetched {
+		return nil, fmt.Errorf("rates of %s are not loaded", date)
+	}
+
+	rates, sourceDate, err := c.s.db.GetExchangeRates(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rates) == 0 {
+		t, err := time.Parse(rateDateLayout, date)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := c.s.historicalRates.HistoricalRates(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = r.Rates
+		sourceDate = r.UpdatedAt.Format(rateDateLayout)
+
+		if err := c.s.db.SaveExchangeRates(ctx, date, sourceDate, rates); err != nil {
+			return nil, err
+		}
+	}
+
+	t, _ := time.ParseInLocation(rateDateLayout, sourceDate, time.Local)
+
+	hr := &historicalRates{
+		cc:   convertor.NewCurrencyConvertor(rates, t),
+		date: sourceDate,
+	}
+	c.rates[date] = hr
+
+	return hr, nil
+}
@@ -0,0 +1,85 @@
+package balance
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubHistoricalRates struct{}
+
+func (stubHistoricalRates) Name() string {
+	return "stub"
+}
+
+func (stubHistoricalRates) HistoricalRates(ctx context.Context, date time.Time) (*convertor.Rates, error) {
+	return &convertor.Rates{Rates: map[string]float64{"RUB": 1}, UpdatedAt: date}, nil
+}
+
+// Without historical provider, e.g. with RATE_PROVIDERS=file and no exchangeratesapi token,
+// historical mode is rejected before history is read.
+func TestHistoricalModeWithoutProvider(t *testing.T) {
+	s := &Service{
+		cConvertor: convertor.NewCurrencyConvertor(map[string]float64{"RUB": 1, "USD": 0.0137}, time.Now()),
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+	}{
+		{"history", s.TransactionsHistory, "/api/balance/history?id=1&currency=USD&rate_mode=historical"},
+		{"export", s.ExportHistory, "/api/balance/history/export?id=1&currency=USD&rate_mode=historical"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+
+			if !strings.Contains(w.Body.String(), "historical rates are not supported") {
+				t.Errorf("body = %s, want historical rates error", w.Body)
+			}
+		})
+	}
+}
+
+func TestNewTransactionConvertorRateMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		rateMode   string
+		hr         convertor.HistoricalRateProvider
+		historical bool
+		wantErr    bool
+	}{
+		{"default", "", nil, false, false},
+		{"current", "current", nil, false, false},
+		{"historical", "historical", stubHistoricalRates{}, true, false},
+		{"historical without provider", "historical", nil, false, true},
+		{"unknown", "yesterday", stubHistoricalRates{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{historicalRates: tt.hr}
+
+			tc, err := s.newTransactionConvertor(url.Values{"rate_mode": {tt.rateMode}}, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newTransactionConvertor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && tc.historical != tt.historical {
+				t.Errorf("historical = %v, want %v", tc.historical, tt.historical)
+			}
+		})
+	}
+}
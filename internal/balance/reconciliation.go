@@ -0,0 +1,130 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/job"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CreateReconciliationReport POST /api/admin/reconciliation
+func (s *Service) CreateReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	report, err := s.db.CreateReconciliationReport(ctx)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(2, "Failed to reconcile ledger"))
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(newReconciliationReportResponse(report)))
+}
+
+// GetReconciliationReports GET /api/admin/reconciliation
+func (s *Service) GetReconciliationReports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 10
+	}
+
+	if limit <= 0 || limit > 100 {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Limit must be > 0 and <= 100"))
+		return
+	}
+
+	reports, err := s.db.GetReconciliationReports(ctx, limit)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get reconciliation reports"))
+		return
+	}
+
+	response := v1.GetReconciliationReportsResponse{
+		Reports: []*v1.ReconciliationReport{},
+	}
+
+	for _, report := range reports {
+		response.Reports = append(response.Reports, newReconciliationReportResponse(report))
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, response)
+}
+
+// GetReconciliationReport GET /api/admin/reconciliation/{reportID}
+func (s *Service) GetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	report, err := s.db.GetReconciliationReport(ctx, id)
+	if err != nil {
+		if errors.Is(err, balanceDB.ErrReportNotFound) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Reconciliation report not found"))
+		} else {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get reconciliation report"))
+		}
+
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, newReconciliationReportResponse(report))
+}
+
+// RunReconciler reconciles ledger every interval until ctx is done.
+func (s *Service) RunReconciler(ctx context.Context, interval time.Duration) {
+	job.Every(ctx, interval, func(ctx context.Context) {
+		report, err := s.db.CreateReconciliationReport(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to reconcile ledger: %s", err)
+			}
+
+			return
+		}
+
+		if !report.IsClean() {
+			log.Printf("reconciliation report #%d: %d balance mismatches, %d orphan transactions",
+				report.ID, report.MismatchCount, report.OrphanCount)
+		}
+	})
+}
+
+func newReconciliationReportResponse(r *model.ReconciliationReport) *v1.ReconciliationReport {
+	response := v1.ReconciliationReport{
+		ID:            r.ID,
+		MismatchCount: r.MismatchCount,
+		OrphanCount:   r.OrphanCount,
+		CreatedAt:     r.CreatedAt,
+	}
+
+	for _, m := range r.Mismatches {
+		response.Mismatches = append(response.Mismatches, &v1.BalanceMismatch{
+			AccountID: m.AccountID,
+			Currency:  m.Currency,
+			Expected:  m.Expected,
+			Actual:    m.Actual,
+		})
+	}
+
+	for _, o := range r.Orphans {
+		response.Orphans = append(response.Orphans, &v1.OrphanTransaction{
+			TransactionID: o.TransactionID,
+			AccountID:     o.AccountID,
+		})
+	}
+
+	return &response
+}
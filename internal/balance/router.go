@@ -4,12 +4,41 @@ import "github.com/go-chi/chi/v5"
 
 // Routes add new routes to chi Router.
 func (s *Service) Routes(r chi.Router) {
+	r.Route("/accounts", func(r chi.Router) {
+		r.Post("/", s.CreateAccount)
+		r.Get("/{accountID}", s.GetAccount)
+		r.Post("/{accountID}/freeze", s.FreezeAccount)
+		r.Post("/{accountID}/unfreeze", s.UnfreezeAccount)
+		r.Post("/{accountID}/close", s.CloseAccount)
+	})
+
+	r.Route("/admin/reconciliation", func(r chi.Router) {
+		r.Post("/", s.CreateReconciliationReport)
+		r.Get("/", s.GetReconciliationReports)
+		r.Get("/{reportID}", s.GetReconciliationReport)
+	})
+
+	r.Get("/currencies", s.GetCurrencies)
+
 	r.Route("/balance", func(r chi.Router) {
 		r.Get("/", s.GetBalance)
 		r.Post("/", s.ControlBalance)
 
 		r.Get("/history", s.TransactionsHistory)
+		r.Get("/history/export", s.ExportHistory)
+		r.Get("/statement", s.GetStatement)
+		r.Get("/turnover", s.Turnover)
+
+		r.Get("/transactions/{transactionID}", s.GetTransaction)
+		r.Post("/transactions/{transactionID}/reverse", s.ReverseTransaction)
 
 		r.Post("/transfer", s.Transfer)
+
+		r.Route("/hold", func(r chi.Router) {
+			r.Post("/", s.CreateHold)
+			r.Get("/{holdID}", s.GetHold)
+			r.Post("/{holdID}/capture", s.CaptureHold)
+			r.Post("/{holdID}/release", s.ReleaseHold)
+		})
 	})
 }
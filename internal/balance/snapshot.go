@@ -0,0 +1,28 @@
+package balance
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/job"
+	"log"
+	"time"
+)
+
+// settleLag is a delay from now after which history is considered immutable. Time of transaction is set
+// before it is committed, so snapshots and rollups are built only when transactions created earlier are surely finished.
+const settleLag = 5 * time.Minute
+
+// RunBalanceSnapshotter saves balances of all wallets every interval until ctx is done.
+func (s *Service) RunBalanceSnapshotter(ctx context.Context, interval time.Duration) {
+	job.Every(ctx, interval, func(ctx context.Context) {
+		count, err := s.db.CreateBalanceSnapshots(ctx, time.Now().Add(-settleLag))
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to create balance snapshots: %s", err)
+			}
+
+			return
+		}
+
+		log.Printf("created %d balance snapshots", count)
+	})
+}
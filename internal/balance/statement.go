@@ -0,0 +1,162 @@
+package balance
+
+import (
+	"bytes"
+	"errors"
+	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var statementTemplate = template.Must(template.New("statement").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Statement of account #{{.AccountID}}</title>
+</head>
+<body>
+<h1>Statement of account #{{.AccountID}}</h1>
+<p>Period: {{.From.Format "2006-01-02 15:04:05"}} &ndash; {{.To.Format "2006-01-02 15:04:05"}}, currency: {{.Currency}}</p>
+<p>Opening balance: {{.OpeningBalance}}</p>
+<table border="1">
+<tr><th>ID</th><th>Date</th><th>From</th><th>To</th><th>Comment</th><th>Amount</th><th>Balance</th></tr>
+{{- range .Transactions}}
+<tr><td>{{.ID}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04:05"}}</td><td>{{.IDFrom}}</td><td>{{.IDTo}}</td><td>{{.Comment}}</td><td>{{.Amount}}</td><td>{{.Balance}}</td></tr>
+{{- end}}
+</table>
+<p>Total credits: {{.TotalCredits}}</p>
+<p>Total debits: {{.TotalDebits}}</p>
+<p>Closing balance: {{.ClosingBalance}}</p>
+</body>
+</html>
+`))
+
+// GetStatement GET /api/balance/statement
+func (s *Service) GetStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	if err := s.validateCurrency(currency); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+		return
+	}
+
+	from, to, err := parseStatementPeriod(r.URL.Query())
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "html" {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Format param must be json or html"))
+		return
+	}
+
+	st, err := s.db.GetStatement(ctx, id, currency, from, to)
+	if err != nil {
+		if errors.Is(err, balanceDB.ErrAccountNotFound) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(3, "Account not found"))
+		} else {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get statement data"))
+		}
+
+		return
+	}
+
+	response := newStatementResponse(st)
+
+	if format == "json" {
+		jsonutil.MarshalResponse(w, http.StatusOK, response)
+		return
+	}
+
+	var buf bytes.Buffer
+
+	if err := statementTemplate.Execute(&buf, response); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot render statement"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// parseStatementPeriod returns period [from, to) set by month param in YYYY-MM format or by from and to params.
+func parseStatementPeriod(query url.Values) (time.Time, time.Time, error) {
+	if month := query.Get("month"); month != "" {
+		from, err := time.ParseInLocation("2006-01", month, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Month param must be in YYYY-MM format")
+		}
+
+		return from, from.AddDate(0, 1, 0), nil
+	}
+
+	var period [2]time.Time
+
+	for i, param := range []string{"from", "to"} {
+		t, err := time.Parse(time.RFC3339, query.Get(param))
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Month param or From and To params in RFC 3339 format must be set")
+		}
+
+		period[i] = t.Local()
+	}
+
+	if !period[0].Before(period[1]) {
+		return time.Time{}, time.Time{}, errors.New("From param must be before To param")
+	}
+
+	return period[0], period[1], nil
+}
+
+func newStatementResponse(st *model.Statement) *v1.GetStatementResponse {
+	response := v1.GetStatementResponse{
+		AccountID:      st.AccountID,
+		Currency:       st.Currency,
+		From:           st.From,
+		To:             st.To,
+		OpeningBalance: st.OpeningBalance,
+		ClosingBalance: st.ClosingBalance,
+		TotalCredits:   st.TotalCredits,
+		TotalDebits:    st.TotalDebits,
+		Transactions:   []*v1.StatementTransaction{},
+	}
+
+	for _, l := range st.Lines {
+		response.Transactions = append(response.Transactions, &v1.StatementTransaction{
+			ID:         l.Transaction.ID,
+			IDFrom:     l.Transaction.IDFrom,
+			IDTo:       l.Transaction.IDTo,
+			Amount:     l.Amount,
+			Balance:    l.Balance,
+			Comment:    l.Transaction.Comment,
+			ReversalOf: l.Transaction.ReversalOf,
+			CreatedAt:  l.Transaction.CreatedAt,
+		})
+	}
+
+	return &response
+}
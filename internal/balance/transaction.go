@@ -0,0 +1,189 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GetTransaction GET /api/balance/transactions/{transactionID}
+func (s *Service) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "transactionID"), 10, 64)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		if err := s.validateCurrency(currency); err != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+			return
+		}
+	}
+
+	tc, err := s.newTransactionConvertor(r.URL.Query(), currency)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	th, err := s.db.GetTransaction(ctx, id)
+	if err != nil {
+		if errors.Is(err, balanceDB.ErrTransactionNotFound) {
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(11, "Transaction not found"))
+		} else {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get transaction data"))
+		}
+
+		return
+	}
+
+	t, err := s.newTransactionResponse(ctx, th, tc)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, err.Error()))
+		return
+	}
+
+	jsonutil.MarshalResponse(w, http.StatusOK, t)
+}
+
+// newTransactionResponse converts transaction amount to currency of tc.
+func (s *Service) newTransactionResponse(ctx context.Context, th *model.TransactionHistory, tc *transactionConvertor) (*v1.Transaction, error) {
+	amount, rateDate, err := tc.convert(ctx, th)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := tc.currency
+	if currency == "" {
+		currency = th.Currency
+	}
+
+	t := v1.Transaction{
+		ID:         th.ID,
+		IDFrom:     th.IDFrom,
+		IDTo:       th.IDTo,
+		Amount:     amount,
+		Currency:   currency,
+		RateDate:   rateDate,
+		CreatedAt:  th.CreatedAt,
+		Comment:    th.Comment,
+		Metadata:   th.Metadata,
+		ReversalOf: th.ReversalOf,
+	}
+
+	if th.FX != nil {
+		t.AmountTo = &th.FX.AmountTo
+		t.CurrencyTo = th.FX.CurrencyTo
+		t.FXRate = th.FX.Rate
+		t.FXRateAt = &th.FX.RateAt
+	}
+
+	return &t, nil
+}
+
+type reverseTransactionRequest struct {
+	Amount      money.Amount `json:"amount"`
+	RequestedBy string       `json:"requested_by"`
+	Reason      string       `json:"reason"`
+}
+
+func (r *reverseTransactionRequest) validate() error {
+	if r.Amount < 0 {
+		return errors.New("amount must be >= 0")
+	}
+
+	if strings.TrimSpace(r.RequestedBy) == "" {
+		return errors.New("requested_by must be not empty")
+	}
+
+	if strings.TrimSpace(r.Reason) == "" {
+		return errors.New("reason must be not empty")
+	}
+
+	return nil
+}
+
+// ReverseTransaction POST /api/balance/transactions/{transactionID}/reverse
+func (s *Service) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "transactionID"), 10, 64)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+		return
+	}
+
+	var req reverseTransactionRequest
+
+	unmarshallStatusCode, err := jsonutil.Unmarshal(w, r, &req)
+	if err != nil {
+		jsonutil.MarshalResponse(w, unmarshallStatusCode, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	key, err := s.newIdempotencyKey(r, req)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	th := model.TransactionHistory{
+		Amount:     req.Amount,
+		Comment:    fmt.Sprintf("Reversal of transaction #%d requested by %s: %s", id, req.RequestedBy, req.Reason),
+		ReversalOf: &id,
+	}
+
+	th.Prepare()
+
+	err = s.db.ReverseTransaction(ctx, &th, key)
+	if err != nil {
+		if writeAccountStatusError(w, err) {
+			return
+		}
+
+		switch {
+		case errors.Is(err, balanceDB.ErrTransactionNotFound):
+			jsonutil.MarshalResponse(w, http.StatusNotFound, jsonutil.NewError(11, "Transaction not found"))
+		case errors.Is(err, balanceDB.ErrReverseOfReversal):
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(12, "Reversal can't be reversed"))
+		case errors.Is(err, balanceDB.ErrAmountTooSmall):
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, "Reversal amount is too small to convert"))
+		case errors.Is(err, balanceDB.ErrReversalExceedsAmount):
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(13, "Reversal amount exceeds not reversed amount of transaction"))
+		case errors.Is(err, balanceDB.ErrBalanceMustBePositive):
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(5, "After reversal receiver balance will be < 0"))
+		case errors.Is(err, balanceDB.ErrIdempotencyKeyConflict):
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(8, "Idempotency key already used with another request"))
+		case errors.Is(err, balanceDB.ErrIdempotencyKeyLegacy):
+			jsonutil.MarshalResponse(w, http.StatusUnprocessableEntity, jsonutil.NewError(17, "Idempotency key was saved before transaction results were stored and can't be replayed"))
+		default:
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(2, "Failed to reverse transaction"))
+		}
+
+		return
+	}
+
+	markIdempotentReplay(w, key)
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(v1.CreateTransactionResponse{
+		TransactionID: th.ID,
+	}))
+}
@@ -1,30 +1,99 @@
 package balance
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	balanceDB "github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/database"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/convertor"
 	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"math/big"
 	"net/http"
 )
 
 type transferRequest struct {
-	IDFrom  int     `json:"id_from"`
-	IDTo    int     `json:"id_to"`
-	Amount  float64 `json:"amount"`
-	Comment string  `json:"comment"`
+	IDFrom     int             `json:"id_from"`
+	IDTo       int             `json:"id_to"`
+	Amount     money.Amount    `json:"amount"`
+	Currency   string          `json:"currency"`
+	AmountTo   money.Amount    `json:"amount_to"`
+	ToCurrency string          `json:"to_currency"`
+	Comment    string          `json:"comment"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
 }
 
 func (r *transferRequest) validate() error {
-	if r.IDFrom == 0 || r.IDTo == 0 {
+	if r.IDFrom <= 0 || r.IDTo <= 0 {
 		return errors.New("you can't transfer money to/from system")
 	}
 
-	if r.Amount <= 0 {
+	if r.Amount < 0 || r.AmountTo < 0 {
 		return errors.New("amount must be > 0")
 	}
 
+	if (r.Amount == 0) == (r.AmountTo == 0) {
+		return errors.New("exactly one of amount and amount_to must be set")
+	}
+
+	if err := validateMetadata(&r.Metadata); err != nil {
+		return err
+	}
+
+	if r.Currency == "" {
+		r.Currency = defaultCurrency
+	}
+
+	if r.ToCurrency == "" {
+		r.ToCurrency = r.Currency
+	}
+
+	return nil
+}
+
+// exchange fills amounts of cross-currency transfer by current rate. Amount fixed in request is kept as is.
+func (s *Service) exchange(th *model.TransactionHistory, req *transferRequest) error {
+	if req.ToCurrency == req.Currency {
+		th.Amount = req.Amount
+		if th.Amount == 0 {
+			th.Amount = req.AmountTo
+		}
+
+		return nil
+	}
+
+	rate, rateAt, err := s.cConvertor.Rate(req.Currency, req.ToCurrency)
+	if err != nil {
+		return err
+	}
+
+	fx := model.FXConversion{
+		AmountTo:   req.AmountTo,
+		CurrencyTo: req.ToCurrency,
+		Rate:       rate.FloatString(convertor.RateScale),
+		RateAt:     rateAt,
+	}
+
+	th.Amount = req.Amount
+
+	if req.Amount != 0 {
+		fx.AmountTo, err = money.FromRat(new(big.Rat).Mul(req.Amount.Rat(), rate))
+	} else {
+		th.Amount, err = money.FromRat(new(big.Rat).Quo(req.AmountTo.Rat(), rate))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if th.Amount <= 0 || fx.AmountTo <= 0 {
+		return balanceDB.ErrAmountTooSmall
+	}
+
+	th.FX = &fx
+
 	return nil
 }
 
@@ -45,17 +114,40 @@ func (s *Service) Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, currency := range []string{req.Currency, req.ToCurrency} {
+		if err := s.validateCurrency(currency); err != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+			return
+		}
+	}
+
 	th := model.TransactionHistory{
-		IDFrom:  req.IDFrom,
-		IDTo:    req.IDTo,
-		Amount:  req.Amount,
-		Comment: req.Comment,
+		IDFrom:   req.IDFrom,
+		IDTo:     req.IDTo,
+		Currency: req.Currency,
+		Comment:  req.Comment,
+		Metadata: req.Metadata,
+	}
+
+	if err := s.exchange(&th, &req); err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+		return
 	}
 
 	th.Prepare()
 
-	err = s.db.Transfer(ctx, &th)
+	key, err := s.newIdempotencyKey(r, req)
 	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, err.Error()))
+		return
+	}
+
+	err = s.db.Transfer(ctx, &th, key)
+	if err != nil {
+		if writeAccountStatusError(w, err) {
+			return
+		}
+
 		if errors.Is(err, balanceDB.ErrBalanceMustBePositive) {
 			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(5, "After transfer your balance will be < 0"))
 			return
@@ -71,9 +163,23 @@ func (s *Service) Transfer(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if errors.Is(err, balanceDB.ErrIdempotencyKeyConflict) {
+			jsonutil.MarshalResponse(w, http.StatusConflict, jsonutil.NewError(8, "Idempotency key already used with another request"))
+			return
+		}
+
+		if errors.Is(err, balanceDB.ErrIdempotencyKeyLegacy) {
+			jsonutil.MarshalResponse(w, http.StatusUnprocessableEntity, jsonutil.NewError(17, "Idempotency key was saved before transaction results were stored and can't be replayed"))
+			return
+		}
+
 		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(2, "Failed to create transfer"))
 		return
 	}
 
-	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(1))
+	markIdempotentReplay(w, key)
+
+	jsonutil.MarshalResponse(w, http.StatusOK, jsonutil.NewSuccessfulResponse(v1.CreateTransactionResponse{
+		TransactionID: th.ID,
+	}))
 }
@@ -0,0 +1,165 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/balance/model"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/job"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/jsonutil"
+	v1 "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/api/v1"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Turnover GET /api/balance/turnover
+func (s *Service) Turnover(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	response := v1.GetTurnoverResponse{
+		Period:   r.URL.Query().Get("period"),
+		Turnover: []*v1.Turnover{},
+	}
+
+	id := model.TurnoverAllAccounts
+
+	if idParam := r.URL.Query().Get("id"); idParam != "" {
+		var err error
+
+		id, err = strconv.Atoi(idParam)
+		if err != nil || id <= 0 {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Validation error"))
+			return
+		}
+
+		response.AccountID = &id
+	}
+
+	if response.Period == "" {
+		response.Period = model.TurnoverPeriodDay
+	}
+
+	switch response.Period {
+	case model.TurnoverPeriodDay, model.TurnoverPeriodWeek, model.TurnoverPeriodMonth:
+	default:
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "Period param must be day, week or month"))
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		if err := s.validateCurrency(currency); err != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(4, err.Error()))
+			return
+		}
+	}
+
+	for _, v := range []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"from", &response.From},
+		{"to", &response.To},
+	} {
+		t, err := time.Parse(time.RFC3339, r.URL.Query().Get(v.param))
+		if err != nil {
+			jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "From and To params must be RFC 3339 time"))
+			return
+		}
+
+		*v.dest = t.Local()
+	}
+
+	if !response.From.Before(response.To) {
+		jsonutil.MarshalResponse(w, http.StatusBadRequest, jsonutil.NewError(3, "From param must be before To param"))
+		return
+	}
+
+	turnover, counts, err := s.db.GetTurnover(ctx, id, response.Period, response.From, response.To)
+	if err != nil {
+		jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(3, "Cannot get turnover data"))
+		return
+	}
+
+	if currency != "" {
+		turnover, err = s.convertTurnover(turnover, counts, currency)
+		if err != nil {
+			jsonutil.MarshalResponse(w, http.StatusInternalServerError, jsonutil.NewError(4, err.Error()))
+			return
+		}
+	}
+
+	for _, t := range turnover {
+		response.Turnover = append(response.Turnover, &v1.Turnover{
+			Period:   t.Period,
+			Currency: t.Currency,
+			Incoming: t.Incoming,
+			Outgoing: t.Outgoing,
+			Net:      t.Net(),
+			Count:    t.Count,
+		})
+	}
+
+	// Settled history doesn't change, so turnover of past periods can be cached for long.
+	// Turnover is data of one account, so it mustn't be stored by shared caches.
+	maxAge := time.Minute
+	if response.To.Before(time.Now().Add(-settleLag)) {
+		maxAge = 24 * time.Hour
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+
+	jsonutil.MarshalResponse(w, http.StatusOK, response)
+}
+
+// convertTurnover converts turnover in all currencies to currency and merges it by period.
+// Count of merged turnover is taken from counts, so transaction made in two currencies is counted once.
+func (s *Service) convertTurnover(turnover []*model.Turnover, counts []*model.TurnoverCount, currency string) ([]*model.Turnover, error) {
+	var result []*model.Turnover
+
+	periodCounts := make(map[string]int, len(counts))
+	for _, c := range counts {
+		periodCounts[c.Period.String()] = c.Count
+	}
+
+	for _, t := range turnover {
+		incoming, err := s.cConvertor.Convert(t.Incoming, t.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		outgoing, err := s.cConvertor.Convert(t.Outgoing, t.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &model.Turnover{
+			Period:   t.Period,
+			Currency: currency,
+			Incoming: incoming,
+			Outgoing: outgoing,
+			Count:    periodCounts[t.Period.String()],
+		}
+
+		// Turnover is sorted by period.
+		if len(result) > 0 && result[len(result)-1].Period.Equal(c.Period) {
+			result[len(result)-1].Incoming += c.Incoming
+			result[len(result)-1].Outgoing += c.Outgoing
+		} else {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// RunTurnoverRollup adds settled days to turnover rollup every interval until ctx is done.
+func (s *Service) RunTurnoverRollup(ctx context.Context, interval time.Duration) {
+	job.Every(ctx, interval, func(ctx context.Context) {
+		err := s.db.RefreshTurnoverRollup(ctx, time.Now().Add(-settleLag))
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("failed to refresh turnover rollup: %s", err)
+		}
+	})
+}
@@ -1,15 +1,34 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v4"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config struct.
 type Config struct {
-	Port      string
-	PgURL     string
-	EAPIToken string
+	Port              string
+	PgURL             string
+	EAPIToken         string
+	EAPIURL           string
+	RateProviders     []string
+	CBRURL            string
+	RatesFile         string
+	IdempotencyKeyTTL time.Duration
+	IdempotencyPurge  time.Duration
+	HoldSweepInterval time.Duration
+	SnapshotInterval  time.Duration
+	RollupInterval    time.Duration
+	ReconcileInterval time.Duration
+	RatesInterval     time.Duration
+	RatesStaleAfter   time.Duration
+	TxMaxRetries      int
+	TxIsoLevel        pgx.TxIsoLevel
 }
 
 // New config.
@@ -24,15 +43,96 @@ func New() (*Config, error) {
 		return nil, err
 	}
 
-	eAPIToken, err := getEnv("EXCHANGERATESAPI_TOKEN")
+	rateProviders := strings.Split(getEnvDefault("RATE_PROVIDERS", "exchangeratesapi"), ",")
+
+	for i, p := range rateProviders {
+		rateProviders[i] = strings.TrimSpace(p)
+
+		switch rateProviders[i] {
+		case "exchangeratesapi", "cbr", "file":
+		default:
+			return nil, fmt.Errorf("env variable RATE_PROVIDERS has unknown provider %q", rateProviders[i])
+		}
+	}
+
+	eAPIToken := getEnvDefault("EXCHANGERATESAPI_TOKEN", "")
+	if eAPIToken == "" && contains(rateProviders, "exchangeratesapi") {
+		return nil, errors.New("env variable EXCHANGERATESAPI_TOKEN not presented")
+	}
+
+	ratesFile := getEnvDefault("RATES_FILE", "")
+	if ratesFile == "" && contains(rateProviders, "file") {
+		return nil, errors.New("env variable RATES_FILE not presented")
+	}
+
+	idempotencyKeyTTL, err := getPositiveDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyPurge, err := getPositiveDurationEnv("IDEMPOTENCY_KEY_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	holdSweepInterval, err := getPositiveDurationEnv("HOLD_SWEEP_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotInterval, err := getPositiveDurationEnv("BALANCE_SNAPSHOT_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	rollupInterval, err := getPositiveDurationEnv("TURNOVER_ROLLUP_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	reconcileInterval, err := getPositiveDurationEnv("RECONCILE_INTERVAL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	ratesInterval, err := getPositiveDurationEnv("RATES_REFRESH_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	ratesStaleAfter, err := getPositiveDurationEnv("RATES_STALE_AFTER", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	txMaxRetries, err := getIntEnv("TX_MAX_RETRIES", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	txIsoLevel, err := getIsoLevelEnv("TX_ISOLATION_LEVEL", pgx.ReadCommitted)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Config{
-		Port:      port,
-		PgURL:     pgURL,
-		EAPIToken: eAPIToken,
+		Port:              port,
+		PgURL:             pgURL,
+		EAPIToken:         eAPIToken,
+		EAPIURL:           getEnvDefault("EXCHANGERATESAPI_URL", ""),
+		RateProviders:     rateProviders,
+		CBRURL:            getEnvDefault("CBR_URL", ""),
+		RatesFile:         ratesFile,
+		IdempotencyKeyTTL: idempotencyKeyTTL,
+		IdempotencyPurge:  idempotencyPurge,
+		HoldSweepInterval: holdSweepInterval,
+		SnapshotInterval:  snapshotInterval,
+		RollupInterval:    rollupInterval,
+		ReconcileInterval: reconcileInterval,
+		RatesInterval:     ratesInterval,
+		RatesStaleAfter:   ratesStaleAfter,
+		TxMaxRetries:      txMaxRetries,
+		TxIsoLevel:        txIsoLevel,
 	}, nil
 }
 
@@ -44,3 +144,79 @@ func getEnv(key string) (string, error) {
 
 	return "", fmt.Errorf("env variable %s not presented", key)
 }
+
+func getEnvDefault(key string, def string) string {
+	value, isFounded := os.LookupEnv(key)
+	if !isFounded {
+		return def
+	}
+
+	return value
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getDurationEnv(key string, def time.Duration) (time.Duration, error) {
+	value, isFounded := os.LookupEnv(key)
+	if !isFounded {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("env variable %s is not valid duration: %w", key, err)
+	}
+
+	return d, nil
+}
+
+func getPositiveDurationEnv(key string, def time.Duration) (time.Duration, error) {
+	d, err := getDurationEnv(key, def)
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("env variable %s must be positive duration", key)
+	}
+
+	return d, nil
+}
+
+func getIntEnv(key string, def int) (int, error) {
+	value, isFounded := os.LookupEnv(key)
+	if !isFounded {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("env variable %s must be not negative integer", key)
+	}
+
+	return i, nil
+}
+
+func getIsoLevelEnv(key string, def pgx.TxIsoLevel) (pgx.TxIsoLevel, error) {
+	value, isFounded := os.LookupEnv(key)
+	if !isFounded {
+		return def, nil
+	}
+
+	isoLevel := pgx.TxIsoLevel(strings.ToLower(value))
+
+	switch isoLevel {
+	case pgx.ReadCommitted, pgx.RepeatableRead, pgx.Serializable:
+		return isoLevel, nil
+	}
+
+	return "", fmt.Errorf("env variable %s must be one of: %s, %s, %s", key, pgx.ReadCommitted, pgx.RepeatableRead, pgx.Serializable)
+}
@@ -0,0 +1,62 @@
+package convertor
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/cbr"
+	"time"
+)
+
+// cbrBase is a currency of rates of Central Bank of Russia.
+const cbrBase = "RUB"
+
+// CBRProvider returns official rates of Central Bank of Russia.
+type CBRProvider struct {
+	client *cbr.Client
+}
+
+// NewCBRProvider returns new CBRProvider.
+func NewCBRProvider(client *cbr.Client) *CBRProvider {
+	return &CBRProvider{client: client}
+}
+
+// Name implements RateProvider.
+func (p *CBRProvider) Name() string {
+	return "cbr"
+}
+
+// Rates implements RateProvider.
+func (p *CBRProvider) Rates(ctx context.Context) (*Rates, error) {
+	daily, err := p.client.GetDailyRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.rates(daily), nil
+}
+
+// HistoricalRates implements HistoricalRateProvider.
+func (p *CBRProvider) HistoricalRates(ctx context.Context, date time.Time) (*Rates, error) {
+	daily, err := p.client.GetRatesOnDate(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.rates(daily), nil
+}
+
+func (p *CBRProvider) rates(daily *cbr.DailyRates) *Rates {
+	rates := map[string]float64{cbrBase: 1}
+
+	// Feed has price of currency in RUB, convertor needs count of currency units for one RUB.
+	for _, r := range daily.Rates {
+		rates[r.CharCode] = float64(r.Nominal) / r.Value
+	}
+
+	return &Rates{
+		Base:      cbrBase,
+		Rates:     rates,
+		UpdatedAt: daily.Date,
+		Source:    p.Name(),
+		FetchedAt: time.Now(),
+	}
+}
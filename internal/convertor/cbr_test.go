@@ -0,0 +1,74 @@
+package convertor
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/cbr"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCBRProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="windows-1251"?>
+<ValCurs Date="02.10.2021" name="Foreign Currency Market">
+<Valute><CharCode>USD</CharCode><Nominal>1</Nominal><Value>72,5</Value></Valute>
+<Valute><CharCode>JPY</CharCode><Nominal>100</Nominal><Value>65</Value></Valute>
+</ValCurs>`))
+	}))
+	defer srv.Close()
+
+	r, err := NewCBRProvider(cbr.New(srv.URL)).Rates(context.Background())
+	if err != nil {
+		t.Fatalf("Rates() error = %v", err)
+	}
+
+	if r.Base != "RUB" || r.Source != "cbr" {
+		t.Errorf("Base, Source = %s, %s, want RUB, cbr", r.Base, r.Source)
+	}
+
+	// Rates are counts of currency units for one RUB.
+	want := map[string]float64{"RUB": 1, "USD": 1 / 72.5, "JPY": 100 / 65.0}
+	for currency, rate := range want {
+		if math.Abs(r.Rates[currency]-rate) > 1e-12 {
+			t.Errorf("Rates[%s] = %v, want %v", currency, r.Rates[currency], rate)
+		}
+	}
+
+	cc := NewCurrencyConvertorFromRates(r)
+
+	amount, err := cc.Convert(10000, "USD", "RUB")
+	if err != nil || amount != 725000 {
+		t.Errorf("Convert(100.00 USD) = %v, %v, want 7250.00 RUB", amount, err)
+	}
+}
+
+func TestCBRProviderHistoricalRates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("date_req") != "03/10/2021" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`<ValCurs Date="02.10.2021"><Valute><CharCode>USD</CharCode><Nominal>1</Nominal><Value>72,5</Value></Valute></ValCurs>`))
+	}))
+	defer srv.Close()
+
+	date := time.Date(2021, 10, 3, 0, 0, 0, 0, time.Local)
+
+	r, err := NewCBRProvider(cbr.New(srv.URL)).HistoricalRates(context.Background(), date)
+	if err != nil {
+		t.Fatalf("HistoricalRates() error = %v", err)
+	}
+
+	// Rates of Sunday are set on Saturday.
+	if want := time.Date(2021, 10, 2, 0, 0, 0, 0, time.Local); !r.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", r.UpdatedAt, want)
+	}
+
+	if math.Abs(r.Rates["USD"]-1/72.5) > 1e-12 {
+		t.Errorf("Rates[USD] = %v, want %v", r.Rates["USD"], 1/72.5)
+	}
+}
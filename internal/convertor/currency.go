@@ -1,36 +1,171 @@
 package convertor
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/internal/job"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"log"
+	"math/big"
+	"sync"
+	"time"
 )
 
+// RateScale is a count of fractional digits of rate returned by Rate.
+const RateScale = 10
+
 // CurrencyConvertor is a struct with map and method to convert currency.
+// It is safe for concurrent use, rates can be replaced by Update.
 type CurrencyConvertor struct {
-	currency map[string]float64
+	mu          sync.RWMutex
+	currency    map[string]float64
+	updatedAt   time.Time
+	base        string
+	source      string
+	refreshedAt time.Time
+}
+
+// NewCurrencyConvertor returns new CurrencyConvertor with rates actual at updatedAt.
+func NewCurrencyConvertor(list map[string]float64, updatedAt time.Time) *CurrencyConvertor {
+	return &CurrencyConvertor{currency: list, updatedAt: updatedAt, refreshedAt: time.Now()}
+}
+
+// NewCurrencyConvertorFromRates returns new CurrencyConvertor with rate table fetched from provider.
+func NewCurrencyConvertorFromRates(r *Rates) *CurrencyConvertor {
+	var cc CurrencyConvertor
+	cc.Update(r)
+
+	return &cc
+}
+
+// Update replaces rate table with r. Rates of r must not be modified after call.
+func (cc *CurrencyConvertor) Update(r *Rates) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.currency = r.Rates
+	cc.updatedAt = r.UpdatedAt
+	cc.base = r.Base
+	cc.source = r.Source
+	cc.refreshedAt = r.FetchedAt
+}
+
+// RefreshedAt returns time when current rates were fetched from provider.
+func (cc *CurrencyConvertor) RefreshedAt() time.Time {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	return cc.refreshedAt
 }
 
-// NewCurrencyConvertor returns new CurrencyConvertor.
-func NewCurrencyConvertor(list map[string]float64) *CurrencyConvertor {
-	return &CurrencyConvertor{currency: list}
+// CurrentRates returns copy of current rate table.
+func (cc *CurrencyConvertor) CurrentRates() *Rates {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	rates := make(map[string]float64, len(cc.currency))
+
+	for currency, rate := range cc.currency {
+		rates[currency] = rate
+	}
+
+	return &Rates{
+		Base:      cc.base,
+		Rates:     rates,
+		UpdatedAt: cc.updatedAt,
+		Source:    cc.source,
+		FetchedAt: cc.refreshedAt,
+	}
 }
 
-// Convert rub amount to another currency.
-func (cc *CurrencyConvertor) Convert(rubAmount float64, toCurrency string) (float64, error) {
+// Refresh updates rates from provider. Current rates are kept if provider fails.
+func (cc *CurrencyConvertor) Refresh(ctx context.Context, provider RateProvider) error {
+	r, err := provider.Rates(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(r.Rates) == 0 {
+		return errors.New("fetched rate table is empty")
+	}
+
+	cc.Update(r)
+
+	return nil
+}
+
+// RunRefresher refreshes rates from provider every interval until ctx is done.
+func (cc *CurrencyConvertor) RunRefresher(ctx context.Context, interval time.Duration, provider RateProvider) {
+	job.Every(ctx, interval, func(ctx context.Context) {
+		if err := cc.Refresh(ctx, provider); err != nil && ctx.Err() == nil {
+			log.Printf("failed to refresh exchange rates, rates of %s are kept: %s", cc.RefreshedAt().Format(time.RFC3339), err)
+		}
+	})
+}
+
+// IsSupported reports whether currency can be converted.
+func (cc *CurrencyConvertor) IsSupported(currency string) bool {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	_, err := cc.rate(currency)
+	return err == nil
+}
+
+// Convert amount from one currency to another.
+func (cc *CurrencyConvertor) Convert(amount money.Amount, fromCurrency string, toCurrency string) (money.Amount, error) {
+	if fromCurrency == toCurrency {
+		return amount, nil
+	}
+
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
 	// API на бесплатной версии предлагает только EUR как base валюту, приходится изворачиваться
-	eurRubCurrency, ok := cc.currency["RUB"]
-	if !ok {
-		return 0, fmt.Errorf("currency %s dosent exist in DB", "RUB")
+	cFromEur, err := cc.rate(fromCurrency)
+	if err != nil {
+		return 0, err
 	}
 
-	eurAmount := rubAmount / eurRubCurrency
+	cToEur, err := cc.rate(toCurrency)
+	if err != nil {
+		return 0, err
+	}
 
-	cToEur, ok := cc.currency[toCurrency]
-	if !ok {
-		return 0, fmt.Errorf("currency %s dosent exist in DB", toCurrency)
+	result := new(big.Rat).Mul(amount.Rat(), cToEur)
+	result.Quo(result, cFromEur)
+
+	return money.FromRat(result)
+}
+
+// Rate returns count of toCurrency units for one unit of fromCurrency rounded to RateScale digits,
+// and time when rate was actual.
+func (cc *CurrencyConvertor) Rate(fromCurrency string, toCurrency string) (*big.Rat, time.Time, error) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	cFromEur, err := cc.rate(fromCurrency)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	cToEur, err := cc.rate(toCurrency)
+	if err != nil {
+		return nil, time.Time{}, err
 	}
 
-	result := eurAmount * cToEur
+	rate, _ := new(big.Rat).SetString(new(big.Rat).Quo(cToEur, cFromEur).FloatString(RateScale))
+
+	return rate, cc.updatedAt, nil
+}
+
+// rate must be called with read lock held.
+func (cc *CurrencyConvertor) rate(currency string) (*big.Rat, error) {
+	c, ok := cc.currency[currency]
+	if !ok || c <= 0 {
+		return nil, fmt.Errorf("currency %s dosent exist in DB", currency)
+	}
 
-	return math.Round(result*100) / 100, nil
+	return new(big.Rat).SetFloat64(c), nil
 }
@@ -0,0 +1,69 @@
+package convertor
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/exchangeratesapi"
+	"time"
+)
+
+// ExchangeratesAPIProvider returns rates of ExchangeratesAPI.
+type ExchangeratesAPIProvider struct {
+	api *exchangeratesapi.ExchangerAPI
+}
+
+// NewExchangeratesAPIProvider returns new ExchangeratesAPIProvider.
+func NewExchangeratesAPIProvider(api *exchangeratesapi.ExchangerAPI) *ExchangeratesAPIProvider {
+	return &ExchangeratesAPIProvider{api: api}
+}
+
+// Name implements RateProvider.
+func (p *ExchangeratesAPIProvider) Name() string {
+	return "exchangeratesapi"
+}
+
+// Rates implements RateProvider.
+func (p *ExchangeratesAPIProvider) Rates(ctx context.Context) (*Rates, error) {
+	list, err := p.api.GetCurrencyList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rates{
+		Base:      list.Base,
+		Rates:     withBase(list.Rates, list.Base),
+		UpdatedAt: time.Unix(int64(list.Timestamp), 0),
+		Source:    p.Name(),
+		FetchedAt: time.Now(),
+	}, nil
+}
+
+// HistoricalRates implements HistoricalRateProvider. Rates of past dates are available only on paid plans of API.
+func (p *ExchangeratesAPIProvider) HistoricalRates(ctx context.Context, date time.Time) (*Rates, error) {
+	list, err := p.api.GetHistoricalCurrencyList(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Date of response is a date of rates, requested date is used if it is missing.
+	updatedAt, err := time.ParseInLocation("2006-01-02", list.Date, time.Local)
+	if err != nil {
+		updatedAt = date
+	}
+
+	return &Rates{
+		Base:      list.Base,
+		Rates:     withBase(list.Rates, list.Base),
+		UpdatedAt: updatedAt,
+		Source:    p.Name(),
+		FetchedAt: time.Now(),
+	}, nil
+}
+
+// withBase adds base currency with rate 1 to rates, so it can be converted too.
+func withBase(rates map[string]float64, base string) map[string]float64 {
+	if _, ok := rates[base]; !ok && base != "" {
+		rates[base] = 1
+	}
+
+	return rates
+}
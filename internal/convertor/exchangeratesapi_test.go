@@ -0,0 +1,77 @@
+package convertor
+
+import (
+	"context"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/exchangeratesapi"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExchangeratesAPIProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest" || r.URL.Query().Get("access_key") != "token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"success": true, "timestamp": 1633046400, "base": "EUR", "date": "2021-10-01", "rates": {"RUB": 84.5, "USD": 1.16}}`))
+	}))
+	defer srv.Close()
+
+	r, err := NewExchangeratesAPIProvider(exchangeratesapi.New("token", srv.URL)).Rates(context.Background())
+	if err != nil {
+		t.Fatalf("Rates() error = %v", err)
+	}
+
+	if r.Base != "EUR" || r.Source != "exchangeratesapi" {
+		t.Errorf("Base, Source = %s, %s, want EUR, exchangeratesapi", r.Base, r.Source)
+	}
+
+	if r.Rates["EUR"] != 1 || r.Rates["RUB"] != 84.5 {
+		t.Errorf("Rates = %v, want EUR: 1, RUB: 84.5", r.Rates)
+	}
+
+	if !r.UpdatedAt.Equal(time.Unix(1633046400, 0)) {
+		t.Errorf("UpdatedAt = %v, want %v", r.UpdatedAt, time.Unix(1633046400, 0))
+	}
+}
+
+func TestExchangeratesAPIProviderError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if _, err := NewExchangeratesAPIProvider(exchangeratesapi.New("token", srv.URL)).Rates(context.Background()); err == nil {
+		t.Error("Rates() error = nil, want error")
+	}
+}
+
+func TestExchangeratesAPIProviderHistoricalRates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2021-10-03" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"success": true, "historical": true, "base": "EUR", "date": "2021-10-01", "rates": {"RUB": 84.5}}`))
+	}))
+	defer srv.Close()
+
+	date := time.Date(2021, 10, 3, 0, 0, 0, 0, time.Local)
+
+	r, err := NewExchangeratesAPIProvider(exchangeratesapi.New("token", srv.URL)).HistoricalRates(context.Background(), date)
+	if err != nil {
+		t.Fatalf("HistoricalRates() error = %v", err)
+	}
+
+	if want := time.Date(2021, 10, 1, 0, 0, 0, 0, time.Local); !r.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", r.UpdatedAt, want)
+	}
+
+	if r.Rates["EUR"] != 1 || r.Rates["RUB"] != 84.5 {
+		t.Errorf("Rates = %v, want EUR: 1, RUB: 84.5", r.Rates)
+	}
+}
@@ -0,0 +1,76 @@
+package convertor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// rateFile is a format of static rates file.
+type rateFile struct {
+	Base      string             `json:"base"`
+	UpdatedAt *time.Time         `json:"updated_at"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+// FileProvider returns rates from static JSON file, for example:
+//
+//	{"base": "EUR", "updated_at": "2021-10-01T00:00:00Z", "rates": {"RUB": 84.5, "USD": 1.16}}
+//
+// File is read on every call, so it can be changed without restart.
+// If updated_at isn't set, modification time of file is used.
+type FileProvider struct {
+	path string
+}
+
+// NewFileProvider returns new FileProvider of file at path.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// Name implements RateProvider.
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+// Rates implements RateProvider.
+func (p *FileProvider) Rates(ctx context.Context) (*Rates, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var rf rateFile
+
+	if err := json.NewDecoder(f).Decode(&rf); err != nil {
+		return nil, err
+	}
+
+	if rf.Base == "" || len(rf.Rates) == 0 {
+		return nil, errors.New("rates file must have base and rates")
+	}
+
+	r := Rates{
+		Base:      rf.Base,
+		Rates:     withBase(rf.Rates, rf.Base),
+		Source:    p.Name(),
+		FetchedAt: time.Now(),
+	}
+
+	if rf.UpdatedAt != nil {
+		r.UpdatedAt = *rf.UpdatedAt
+	} else {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		r.UpdatedAt = info.ModTime()
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,94 @@
+package convertor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRatesFile(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestFileProvider(t *testing.T) {
+	path := writeRatesFile(t, `{"base": "EUR", "updated_at": "2021-10-01T00:00:00Z", "rates": {"RUB": 84.5, "USD": 1.16}}`)
+
+	r, err := NewFileProvider(path).Rates(context.Background())
+	if err != nil {
+		t.Fatalf("Rates() error = %v", err)
+	}
+
+	if r.Base != "EUR" || r.Source != "file" {
+		t.Errorf("Base, Source = %s, %s, want EUR, file", r.Base, r.Source)
+	}
+
+	// Base currency is added, so it can be converted too.
+	want := map[string]float64{"EUR": 1, "RUB": 84.5, "USD": 1.16}
+	for currency, rate := range want {
+		if r.Rates[currency] != rate {
+			t.Errorf("Rates[%s] = %v, want %v", currency, r.Rates[currency], rate)
+		}
+	}
+
+	if wantAt := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC); !r.UpdatedAt.Equal(wantAt) {
+		t.Errorf("UpdatedAt = %v, want %v", r.UpdatedAt, wantAt)
+	}
+
+	if r.FetchedAt.IsZero() {
+		t.Error("FetchedAt is not set")
+	}
+}
+
+func TestFileProviderModTime(t *testing.T) {
+	path := writeRatesFile(t, `{"base": "EUR", "rates": {"RUB": 84.5}}`)
+
+	modTime := time.Date(2021, 9, 30, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewFileProvider(path).Rates(context.Background())
+	if err != nil {
+		t.Fatalf("Rates() error = %v", err)
+	}
+
+	if !r.UpdatedAt.Equal(modTime) {
+		t.Errorf("UpdatedAt = %v, want modification time %v", r.UpdatedAt, modTime)
+	}
+}
+
+func TestFileProviderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no base", `{"rates": {"RUB": 84.5}}`},
+		{"no rates", `{"base": "EUR"}`},
+		{"empty rates", `{"base": "EUR", "rates": {}}`},
+		{"malformed", `{"base":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFileProvider(writeRatesFile(t, tt.data)).Rates(context.Background()); err == nil {
+				t.Error("Rates() error = nil, want error")
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := NewFileProvider(filepath.Join(t.TempDir(), "missing.json")).Rates(context.Background()); err == nil {
+			t.Error("Rates() error = nil, want error")
+		}
+	})
+}
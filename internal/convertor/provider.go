@@ -0,0 +1,183 @@
+package convertor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Rates is a table of rates fetched from provider.
+type Rates struct {
+	// Base is a currency which rate is 1.
+	Base string
+	// Rates are counts of currency units for one unit of Base.
+	Rates map[string]float64
+	// UpdatedAt is a time when rates were actual.
+	UpdatedAt time.Time
+	// Source is a name of provider.
+	Source string
+	// FetchedAt is a time when rates were fetched from provider.
+	FetchedAt time.Time
+}
+
+// RateProvider returns current rate table.
+type RateProvider interface {
+	// Name of provider used as source of rates.
+	Name() string
+	Rates(ctx context.Context) (*Rates, error)
+}
+
+// HistoricalRateProvider returns rate table of past date.
+type HistoricalRateProvider interface {
+	// Name of provider used as source of rates.
+	Name() string
+	// HistoricalRates returns rates actual on date. UpdatedAt of result is a date of rates,
+	// it can be earlier than date if rates weren't set on it.
+	HistoricalRates(ctx context.Context, date time.Time) (*Rates, error)
+}
+
+// RateStore stores fetched rate tables.
+type RateStore interface {
+	SaveCurrencyRates(ctx context.Context, r *Rates) error
+	// GetLatestCurrencyRates returns the most recently fetched rate table.
+	GetLatestCurrencyRates(ctx context.Context) (*Rates, error)
+}
+
+// StoringProvider saves every rate table returned by provider to store.
+type StoringProvider struct {
+	provider RateProvider
+	store    RateStore
+}
+
+// NewStoringProvider returns new StoringProvider.
+func NewStoringProvider(provider RateProvider, store RateStore) *StoringProvider {
+	return &StoringProvider{provider: provider, store: store}
+}
+
+// Name implements RateProvider.
+func (p *StoringProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Rates implements RateProvider. Rates are returned even if they cannot be stored.
+func (p *StoringProvider) Rates(ctx context.Context) (*Rates, error) {
+	r, err := p.provider.Rates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.store.SaveCurrencyRates(ctx, r); err != nil {
+		log.Printf("failed to store exchange rates of %s: %s", r.Source, err)
+	}
+
+	return r, nil
+}
+
+// LoadRates returns rates of provider, or the latest stored rates if provider fails.
+func LoadRates(ctx context.Context, provider RateProvider, store RateStore) (*Rates, error) {
+	r, err := provider.Rates(ctx)
+	if err == nil {
+		return r, nil
+	}
+
+	stored, storeErr := store.GetLatestCurrencyRates(ctx)
+	if storeErr != nil {
+		return nil, fmt.Errorf("%s, stored rates are unavailable: %w", err, storeErr)
+	}
+
+	log.Printf("failed to fetch exchange rates, stored rates of %s fetched at %s are used: %s",
+		stored.Source, stored.FetchedAt.Format(time.RFC3339), err)
+
+	return stored, nil
+}
+
+// FallbackProvider returns rates of first provider which hasn't failed.
+type FallbackProvider struct {
+	providers []RateProvider
+}
+
+// NewFallbackProvider returns provider which tries providers in order.
+func NewFallbackProvider(providers ...RateProvider) *FallbackProvider {
+	return &FallbackProvider{providers: providers}
+}
+
+// Name implements RateProvider.
+func (p *FallbackProvider) Name() string {
+	return strings.Join(p.names(), ",")
+}
+
+// Rates implements RateProvider. Error contains errors of all providers if all of them have failed.
+func (p *FallbackProvider) Rates(ctx context.Context) (*Rates, error) {
+	return fallback(ctx, p.names(), func(i int) (*Rates, error) {
+		return p.providers[i].Rates(ctx)
+	})
+}
+
+func (p *FallbackProvider) names() []string {
+	names := make([]string, 0, len(p.providers))
+
+	for _, provider := range p.providers {
+		names = append(names, provider.Name())
+	}
+
+	return names
+}
+
+// HistoricalFallbackProvider returns historical rates of first provider which hasn't failed.
+type HistoricalFallbackProvider struct {
+	providers []HistoricalRateProvider
+}
+
+// NewHistoricalFallbackProvider returns provider which tries providers in order.
+func NewHistoricalFallbackProvider(providers ...HistoricalRateProvider) *HistoricalFallbackProvider {
+	return &HistoricalFallbackProvider{providers: providers}
+}
+
+// Name implements HistoricalRateProvider.
+func (p *HistoricalFallbackProvider) Name() string {
+	return strings.Join(p.names(), ",")
+}
+
+// HistoricalRates implements HistoricalRateProvider. Error contains errors of all providers if all of them have failed.
+func (p *HistoricalFallbackProvider) HistoricalRates(ctx context.Context, date time.Time) (*Rates, error) {
+	return fallback(ctx, p.names(), func(i int) (*Rates, error) {
+		return p.providers[i].HistoricalRates(ctx, date)
+	})
+}
+
+func (p *HistoricalFallbackProvider) names() []string {
+	names := make([]string, 0, len(p.providers))
+
+	for _, provider := range p.providers {
+		names = append(names, provider.Name())
+	}
+
+	return names
+}
+
+// fallback returns result of first call of fetch which hasn't failed, fetch is called with index of provider in names.
+func fallback(ctx context.Context, names []string, fetch func(i int) (*Rates, error)) (*Rates, error) {
+	if len(names) == 0 {
+		return nil, errors.New("no rate providers")
+	}
+
+	errs := make([]string, 0, len(names))
+
+	for i, name := range names {
+		r, err := fetch(i)
+		if err == nil {
+			return r, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+	}
+
+	return nil, fmt.Errorf("all rate providers failed: %s", strings.Join(errs, "; "))
+}
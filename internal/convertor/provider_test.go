@@ -0,0 +1,123 @@
+package convertor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubProvider returns rates or err and counts calls.
+type stubProvider struct {
+	name  string
+	rates *Rates
+	err   error
+	calls int
+	// onCall is called before result is returned.
+	onCall func()
+}
+
+func (p *stubProvider) Name() string {
+	return p.name
+}
+
+func (p *stubProvider) Rates(ctx context.Context) (*Rates, error) {
+	p.calls++
+
+	if p.onCall != nil {
+		p.onCall()
+	}
+
+	return p.rates, p.err
+}
+
+func (p *stubProvider) HistoricalRates(ctx context.Context, date time.Time) (*Rates, error) {
+	return p.Rates(ctx)
+}
+
+func TestFallbackProviderOrder(t *testing.T) {
+	failing := &stubProvider{name: "failing", err: errors.New("down")}
+	first := &stubProvider{name: "first", rates: &Rates{Source: "first"}}
+	second := &stubProvider{name: "second", rates: &Rates{Source: "second"}}
+
+	p := NewFallbackProvider(failing, first, second)
+
+	r, err := p.Rates(context.Background())
+	if err != nil {
+		t.Fatalf("Rates() error = %v", err)
+	}
+
+	if r.Source != "first" {
+		t.Errorf("Source = %s, want first", r.Source)
+	}
+
+	if failing.calls != 1 || first.calls != 1 || second.calls != 0 {
+		t.Errorf("calls = %d, %d, %d, want 1, 1, 0", failing.calls, first.calls, second.calls)
+	}
+
+	if name := p.Name(); name != "failing,first,second" {
+		t.Errorf("Name() = %s, want failing,first,second", name)
+	}
+}
+
+func TestFallbackProviderAllFailed(t *testing.T) {
+	p := NewFallbackProvider(
+		&stubProvider{name: "a", err: errors.New("timeout")},
+		&stubProvider{name: "b", err: errors.New("bad gateway")},
+	)
+
+	_, err := p.Rates(context.Background())
+	if err == nil {
+		t.Fatal("Rates() error = nil, want error")
+	}
+
+	for _, want := range []string{"a: timeout", "b: bad gateway"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+}
+
+func TestFallbackProviderNoProviders(t *testing.T) {
+	if _, err := NewFallbackProvider().Rates(context.Background()); err == nil {
+		t.Error("Rates() error = nil, want error")
+	}
+}
+
+func TestFallbackProviderContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &stubProvider{name: "first", err: errors.New("canceled"), onCall: cancel}
+	second := &stubProvider{name: "second", rates: &Rates{}}
+
+	_, err := NewFallbackProvider(first, second).Rates(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Rates() error = %v, want context.Canceled", err)
+	}
+
+	if second.calls != 0 {
+		t.Errorf("second provider is called %d times after cancel", second.calls)
+	}
+}
+
+func TestHistoricalFallbackProvider(t *testing.T) {
+	failing := &stubProvider{name: "failing", err: errors.New("function_access_restricted")}
+	second := &stubProvider{name: "second", rates: &Rates{Source: "second"}}
+
+	p := NewHistoricalFallbackProvider(failing, second)
+
+	r, err := p.HistoricalRates(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("HistoricalRates() error = %v", err)
+	}
+
+	if r.Source != "second" || failing.calls != 1 {
+		t.Errorf("Source = %s, failing calls = %d, want second, 1", r.Source, failing.calls)
+	}
+
+	if _, err := NewHistoricalFallbackProvider().HistoricalRates(context.Background(), time.Now()); err == nil {
+		t.Error("HistoricalRates() of no providers error = nil, want error")
+	}
+}
@@ -0,0 +1,29 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every calls f every interval until ctx is done. Calls don't overlap, tick is skipped while f is running.
+// Interval must be positive, job isn't run otherwise.
+func Every(ctx context.Context, interval time.Duration, f func(ctx context.Context)) {
+	if interval <= 0 {
+		log.Printf("job isn't run: interval %s must be positive", interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		f(ctx)
+	}
+}
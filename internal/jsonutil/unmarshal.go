@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
 	"io"
 	"net/http"
 	"strings"
@@ -39,6 +40,8 @@ func Unmarshal(w http.ResponseWriter, r *http.Request, data interface{}) (int, e
 		case strings.HasPrefix(err.Error(), "json: unknown field"):
 			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
 			return http.StatusBadRequest, fmt.Errorf("unknown field %s", fieldName)
+		case errors.Is(err, money.ErrInvalidAmount):
+			return http.StatusBadRequest, err
 		case errors.Is(err, io.EOF):
 			return http.StatusBadRequest, fmt.Errorf("body must not be empty")
 		case err.Error() == "http: request body too large":
@@ -0,0 +1,44 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ValidateObject returns error if data is not JSON object, is longer than maxBytes
+// or has containers nested deeper than maxDepth. Object itself has depth 1.
+func ValidateObject(data json.RawMessage, maxBytes int, maxDepth int) error {
+	if len(data) > maxBytes {
+		return fmt.Errorf("must be <= %d bytes", maxBytes)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	depth := 0
+
+	for first := true; ; first = false {
+		t, err := d.Token()
+		if err != nil {
+			return errors.New("is malformed JSON")
+		}
+
+		if first && t != json.Delim('{') {
+			return errors.New("must be JSON object")
+		}
+
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("must be nested <= %d levels", maxDepth)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE idempotency_keys;
+
+END;
\ No newline at end of file
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE idempotency_keys (
+    key text PRIMARY KEY,
+    request_hash text NOT NULL,
+    response jsonb,
+    created_at timestamp NOT NULL,
+    expires_at timestamp NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+END;
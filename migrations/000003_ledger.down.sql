@@ -0,0 +1,15 @@
+BEGIN;
+
+DROP TRIGGER postings_balanced ON postings;
+DROP FUNCTION check_transaction_balanced();
+DROP TABLE postings;
+
+UPDATE transaction_history SET id_to = 0, amount = -amount WHERE id_to = -2;
+UPDATE transaction_history SET id_from = 0 WHERE id_from = -1;
+
+ALTER TABLE transaction_history DROP COLUMN id;
+
+DELETE FROM accounts WHERE type = 'system';
+ALTER TABLE accounts DROP COLUMN type;
+
+END;
@@ -0,0 +1,55 @@
+BEGIN;
+
+ALTER TABLE accounts
+    ADD COLUMN type text NOT NULL DEFAULT 'user' CHECK (type IN ('user', 'system'));
+
+-- System accounts are sources and sinks of money. Their balance is not stored
+-- in accounts.balance and is computed from postings instead.
+INSERT INTO accounts (id, balance, type) VALUES
+    (-1, 0, 'system'), -- source of balance top ups
+    (-2, 0, 'system')  -- sink of balance withdrawals
+;
+
+-- transaction_history becomes journal: one row per operation, postings hold ledger lines.
+ALTER TABLE transaction_history
+    ADD COLUMN id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY;
+
+UPDATE transaction_history SET id_from = -1 WHERE id_from = 0;
+UPDATE transaction_history SET id_to = -2, amount = -amount WHERE id_to = 0;
+
+CREATE TABLE postings (
+    id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    transaction_id bigint NOT NULL REFERENCES transaction_history (id),
+    account_id int NOT NULL REFERENCES accounts (id),
+    -- Positive amount is credit of account, negative amount is debit.
+    amount numeric(1000, 2) NOT NULL CHECK (amount <> 0),
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX postings_transaction_id_idx ON postings (transaction_id);
+CREATE INDEX postings_account_id_created_at_idx ON postings (account_id, created_at);
+
+INSERT INTO postings
+    (transaction_id, account_id, amount, created_at)
+SELECT id, id_from, -amount, created_at FROM transaction_history
+UNION ALL
+SELECT id, id_to, amount, created_at FROM transaction_history
+;
+
+-- Sum of debits must be equal to sum of credits in every transaction.
+CREATE FUNCTION check_transaction_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT sum(amount) FROM postings WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'transaction % is not balanced', NEW.transaction_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER postings_balanced
+    AFTER INSERT OR UPDATE ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_transaction_balanced();
+
+END;
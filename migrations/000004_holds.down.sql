@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE holds;
+
+ALTER TABLE accounts
+    DROP CONSTRAINT accounts_available_check,
+    DROP COLUMN held;
+
+END;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- balance is total amount on account, held is its reserved part.
+ALTER TABLE accounts
+    ADD COLUMN held numeric(1000, 2) NOT NULL DEFAULT 0 CHECK (held >= 0),
+    ADD CONSTRAINT accounts_available_check CHECK (balance - held >= 0);
+
+CREATE TABLE holds (
+    id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    account_id int NOT NULL REFERENCES accounts (id),
+    amount numeric(1000, 2) NOT NULL CHECK (amount > 0),
+    status text NOT NULL CHECK (status IN ('active', 'captured', 'released')),
+    comment text,
+    transaction_id bigint REFERENCES transaction_history (id),
+    created_at timestamp NOT NULL,
+    expires_at timestamp,
+    updated_at timestamp NOT NULL
+);
+
+CREATE INDEX holds_account_id_idx ON holds (account_id);
+CREATE INDEX holds_expires_at_idx ON holds (expires_at) WHERE status = 'active';
+
+END;
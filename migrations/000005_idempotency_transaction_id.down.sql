@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE idempotency_keys
+    DROP COLUMN transaction_id;
+
+END;
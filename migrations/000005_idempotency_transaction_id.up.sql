@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Idempotency key stores id of created transaction instead of raw response.
+-- Keys saved before have no transaction id, their requests are rejected until keys expire.
+ALTER TABLE idempotency_keys
+    ADD COLUMN transaction_id bigint REFERENCES transaction_history (id);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE transaction_history
+    DROP COLUMN reversal_of;
+
+END;
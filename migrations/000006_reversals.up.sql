@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE transaction_history
+    ADD COLUMN reversal_of bigint REFERENCES transaction_history (id);
+
+CREATE INDEX transaction_history_reversal_of_idx ON transaction_history (reversal_of);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE accounts
+    DROP COLUMN status;
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE accounts
+    ADD COLUMN status text NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen', 'closed'));
+
+END;
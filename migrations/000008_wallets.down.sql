@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION check_transaction_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT sum(amount) FROM postings WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'transaction % is not balanced', NEW.transaction_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE holds DROP COLUMN currency;
+ALTER TABLE postings DROP COLUMN currency;
+ALTER TABLE transaction_history DROP COLUMN currency;
+
+ALTER TABLE accounts
+    ADD COLUMN balance numeric(1000, 2) CHECK (balance >= 0),
+    ADD COLUMN held numeric(1000, 2) NOT NULL DEFAULT 0 CHECK (held >= 0),
+    ADD CONSTRAINT accounts_available_check CHECK (balance - held >= 0);
+
+-- Only RUB wallets can be restored.
+UPDATE accounts a SET balance = w.balance, held = w.held
+FROM wallets w
+WHERE w.account_id = a.id AND w.currency = 'RUB';
+
+UPDATE accounts SET balance = 0 WHERE balance IS NULL;
+
+DROP TABLE wallets;
+
+END;
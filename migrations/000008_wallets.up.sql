@@ -0,0 +1,51 @@
+BEGIN;
+
+-- Balance of account is kept separately for every currency.
+CREATE TABLE wallets (
+    account_id int NOT NULL REFERENCES accounts (id),
+    currency text NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    balance numeric(1000, 2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    held numeric(1000, 2) NOT NULL DEFAULT 0 CHECK (held >= 0),
+    PRIMARY KEY (account_id, currency),
+    CONSTRAINT wallets_available_check CHECK (balance - held >= 0)
+);
+
+INSERT INTO wallets
+    (account_id, currency, balance, held)
+SELECT id, 'RUB', balance, held FROM accounts WHERE type = 'user'
+;
+
+ALTER TABLE accounts
+    DROP CONSTRAINT accounts_available_check,
+    DROP COLUMN balance,
+    DROP COLUMN held;
+
+ALTER TABLE transaction_history
+    ADD COLUMN currency text NOT NULL DEFAULT 'RUB';
+ALTER TABLE transaction_history
+    ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE postings
+    ADD COLUMN currency text NOT NULL DEFAULT 'RUB';
+ALTER TABLE postings
+    ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE holds
+    ADD COLUMN currency text NOT NULL DEFAULT 'RUB';
+ALTER TABLE holds
+    ALTER COLUMN currency DROP DEFAULT;
+
+-- Debits must be equal to credits in every currency of transaction.
+CREATE OR REPLACE FUNCTION check_transaction_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM postings WHERE transaction_id = NEW.transaction_id GROUP BY currency HAVING sum(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'transaction % is not balanced', NEW.transaction_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+END;
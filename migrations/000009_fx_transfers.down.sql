@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE transaction_history
+    DROP COLUMN amount_to,
+    DROP COLUMN currency_to,
+    DROP COLUMN fx_rate,
+    DROP COLUMN fx_rate_at;
+
+-- Fails if cross-currency transfers were made.
+DELETE FROM accounts WHERE id = -3;
+
+END;
@@ -0,0 +1,15 @@
+BEGIN;
+
+INSERT INTO accounts (id, type) VALUES
+    (-3, 'system') -- currency exchange of cross-currency transfers
+;
+
+-- Set only for cross-currency transactions: amount_to in currency_to is credited to receiver,
+-- fx_rate is a count of currency_to units for one unit of currency, actual at fx_rate_at.
+ALTER TABLE transaction_history
+    ADD COLUMN amount_to numeric(1000, 2),
+    ADD COLUMN currency_to text,
+    ADD COLUMN fx_rate numeric,
+    ADD COLUMN fx_rate_at timestamp;
+
+END;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX postings_account_id_transaction_id_idx;
+DROP INDEX transaction_history_amount_id_idx;
+DROP INDEX transaction_history_created_at_id_idx;
+
+END;
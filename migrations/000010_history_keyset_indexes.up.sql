@@ -0,0 +1,10 @@
+BEGIN;
+
+-- Keyset pagination of history: rows are ordered by sort column with id as tie-breaker.
+CREATE INDEX transaction_history_created_at_id_idx ON transaction_history (created_at, id);
+CREATE INDEX transaction_history_amount_id_idx ON transaction_history (amount, id);
+
+-- Lookup of account transactions by postings.
+CREATE INDEX postings_account_id_transaction_id_idx ON postings (account_id, transaction_id);
+
+END;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX postings_account_id_currency_created_at_idx;
+DROP TABLE balance_snapshots;
+
+END;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Balance of account wallet made by postings created before taken_at.
+CREATE TABLE balance_snapshots (
+    account_id int NOT NULL REFERENCES accounts (id),
+    currency text NOT NULL,
+    balance numeric(1000, 2) NOT NULL,
+    taken_at timestamp NOT NULL,
+    PRIMARY KEY (account_id, currency, taken_at)
+);
+
+-- Sum of postings of wallet after snapshot.
+CREATE INDEX postings_account_id_currency_created_at_idx ON postings (account_id, currency, created_at);
+
+END;
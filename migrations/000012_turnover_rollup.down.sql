@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE turnover_rollup_state;
+DROP TABLE turnover_rollup;
+
+END;
@@ -0,0 +1,21 @@
+BEGIN;
+
+-- Daily turnover of user account wallets. Row with account_id = 0 is turnover of all user accounts.
+CREATE TABLE turnover_rollup (
+    day timestamp NOT NULL,
+    account_id int NOT NULL,
+    currency text NOT NULL,
+    incoming numeric(1000, 2) NOT NULL,
+    outgoing numeric(1000, 2) NOT NULL,
+    count int NOT NULL,
+    PRIMARY KEY (account_id, day, currency)
+);
+
+-- Rollup contains all days before refreshed_until.
+CREATE TABLE turnover_rollup_state (
+    refreshed_until timestamp
+);
+
+INSERT INTO turnover_rollup_state (refreshed_until) VALUES (NULL);
+
+END;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX transaction_history_comment_tsv_idx;
+
+ALTER TABLE transaction_history
+    DROP COLUMN comment_tsv;
+
+END;
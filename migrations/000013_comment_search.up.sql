@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Full-text search over comments written in Russian or English.
+ALTER TABLE transaction_history
+    ADD COLUMN comment_tsv tsvector GENERATED ALWAYS AS (
+        to_tsvector('russian', coalesce(comment, '')) || to_tsvector('english', coalesce(comment, ''))
+    ) STORED;
+
+CREATE INDEX transaction_history_comment_tsv_idx ON transaction_history USING gin (comment_tsv);
+
+END;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE exchange_rates;
+
+END;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Daily rates used to convert past transactions. Rate is a count of currency units for one unit of base
+-- of provider which returned rates of date, so rates of one date always have the same base.
+CREATE TABLE exchange_rates (
+    date date NOT NULL,
+    currency text NOT NULL,
+    rate numeric NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (date, currency)
+);
+
+END;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE reconciliation_orphans;
+DROP TABLE reconciliation_mismatches;
+DROP TABLE reconciliation_reports;
+
+END;
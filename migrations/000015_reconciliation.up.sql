@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE reconciliation_reports (
+    id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    mismatch_count int NOT NULL,
+    orphan_count int NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+-- Wallet balance which differs from net of account postings.
+CREATE TABLE reconciliation_mismatches (
+    report_id bigint NOT NULL REFERENCES reconciliation_reports (id) ON DELETE CASCADE,
+    account_id int NOT NULL,
+    currency text NOT NULL,
+    expected numeric(1000, 2) NOT NULL,
+    actual numeric(1000, 2) NOT NULL,
+    PRIMARY KEY (report_id, account_id, currency)
+);
+
+-- History row which points at account that doesn't exist. Empty account id is saved as 0.
+CREATE TABLE reconciliation_orphans (
+    report_id bigint NOT NULL REFERENCES reconciliation_reports (id) ON DELETE CASCADE,
+    transaction_id bigint NOT NULL,
+    account_id int NOT NULL
+);
+
+CREATE INDEX reconciliation_orphans_report_id_idx ON reconciliation_orphans (report_id);
+
+END;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX transaction_history_metadata_idx;
+
+ALTER TABLE transaction_history
+    DROP COLUMN metadata;
+
+END;
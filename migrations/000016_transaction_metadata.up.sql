@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE transaction_history
+    ADD COLUMN metadata jsonb CHECK (jsonb_typeof(metadata) = 'object');
+
+-- Filter of history by metadata key and value.
+CREATE INDEX transaction_history_metadata_idx ON transaction_history USING gin (metadata);
+
+END;
@@ -0,0 +1,21 @@
+BEGIN;
+
+-- Every rate table fetched from rate providers. Rates are counts of currency units for one unit of base.
+-- Table with the latest fetched_at before a moment was in effect at that moment.
+--
+-- It isn't merged with exchange_rates: that table caches official rates of a calendar date requested
+-- to convert past transactions and is filled on demand, so it has gaps and can't show which rates
+-- the service used at a moment. This table is a log of current rates as they were fetched, it is
+-- used at startup if all providers fail and is never read for historical conversion.
+CREATE TABLE currency_rates (
+    id bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    source text NOT NULL,
+    base text NOT NULL,
+    rates jsonb NOT NULL,
+    updated_at timestamp NOT NULL,
+    fetched_at timestamp NOT NULL
+);
+
+CREATE INDEX currency_rates_fetched_at_idx ON currency_rates (fetched_at);
+
+END;
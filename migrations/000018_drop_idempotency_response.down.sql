@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE idempotency_keys
+    ADD COLUMN response jsonb;
+
+END;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Responses aren't replayed since idempotency keys store transaction id.
+ALTER TABLE idempotency_keys
+    DROP COLUMN response;
+
+END;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE turnover_rollup_counts;
+
+END;
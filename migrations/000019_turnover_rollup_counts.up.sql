@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Daily count of transactions of user account in all currencies. Cross-currency transaction has postings
+-- in two currencies, so it can't be summed from counts of turnover_rollup. Row with account_id = 0 is count
+-- of transactions of all user accounts.
+CREATE TABLE turnover_rollup_counts (
+    day timestamp NOT NULL,
+    account_id int NOT NULL,
+    count int NOT NULL,
+    PRIMARY KEY (account_id, day)
+);
+
+-- Days already in rollup are counted from postings.
+INSERT INTO turnover_rollup_counts
+    (day, account_id, count)
+SELECT date_trunc('day', p.created_at), p.account_id, count(DISTINCT p.transaction_id)
+FROM postings p, turnover_rollup_state s
+WHERE p.account_id > 0 AND p.created_at < s.refreshed_until
+GROUP BY 1, 2
+UNION ALL
+SELECT date_trunc('day', p.created_at), 0, count(DISTINCT p.transaction_id)
+FROM postings p, turnover_rollup_state s
+WHERE p.account_id > 0 AND p.created_at < s.refreshed_until
+GROUP BY 1
+;
+
+END;
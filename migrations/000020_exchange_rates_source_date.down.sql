@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE exchange_rates
+    DROP COLUMN source_date;
+
+END;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Provider can return rates of another date than requested one, e.g. of last business day.
+ALTER TABLE exchange_rates
+    ADD COLUMN source_date date;
+
+UPDATE exchange_rates SET source_date = date;
+
+ALTER TABLE exchange_rates
+    ALTER COLUMN source_date SET NOT NULL;
+
+END;
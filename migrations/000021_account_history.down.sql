@@ -0,0 +1,7 @@
+BEGIN;
+
+CREATE INDEX transaction_history_amount_id_idx ON transaction_history (amount, id);
+
+DROP TABLE account_history;
+
+END;
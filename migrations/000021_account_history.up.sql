@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Keyset pagination of account history: sort keys of transaction are copied to one row per account,
+-- so page of account is an index range scan. Postings can't be used: account has two postings in
+-- transfer to itself, and their amounts differ from transaction amount in cross-currency transfer.
+CREATE TABLE account_history (
+    account_id int NOT NULL REFERENCES accounts (id),
+    transaction_id bigint NOT NULL REFERENCES transaction_history (id),
+    amount numeric(1000, 2) NOT NULL,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (account_id, transaction_id)
+);
+
+INSERT INTO account_history
+    (account_id, transaction_id, amount, created_at)
+SELECT DISTINCT p.account_id, th.id, th.amount, th.created_at
+FROM postings p
+JOIN transaction_history th ON th.id = p.transaction_id
+;
+
+CREATE INDEX account_history_account_id_created_at_idx ON account_history (account_id, created_at, transaction_id);
+CREATE INDEX account_history_account_id_amount_idx ON account_history (account_id, amount, transaction_id);
+
+-- Global index doesn't help to page history of account.
+DROP INDEX transaction_history_amount_id_idx;
+
+END;
@@ -0,0 +1,8 @@
+package v1
+
+// Account struct.
+type Account struct {
+	ID      int       `json:"id"`
+	Status  string    `json:"status"`
+	Wallets []*Wallet `json:"wallets"`
+}
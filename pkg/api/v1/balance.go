@@ -1,7 +1,21 @@
 package v1
 
-// GetBalanceResponse struct.
+import "github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+
+// GetBalanceResponse struct. Balance, Available and Held are totals of all wallets converted to Currency.
+// For balance at past time held amounts aren't known and are zero.
 type GetBalanceResponse struct {
-	Balance  float64 `json:"balance"`
-	Currency string  `json:"currency"`
+	Balance   money.Amount `json:"balance"`
+	Available money.Amount `json:"available"`
+	Held      money.Amount `json:"held"`
+	Currency  string       `json:"currency"`
+	Wallets   []*Wallet    `json:"wallets"`
+}
+
+// Wallet struct.
+type Wallet struct {
+	Currency  string       `json:"currency"`
+	Balance   money.Amount `json:"balance"`
+	Available money.Amount `json:"available"`
+	Held      money.Amount `json:"held"`
 }
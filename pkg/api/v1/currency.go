@@ -0,0 +1,21 @@
+package v1
+
+import "time"
+
+// GetCurrenciesResponse struct. Rates are taken from Source at FetchedAt and were actual at UpdatedAt.
+// Stale is set if rates are fetched too long ago, because providers have failed since.
+type GetCurrenciesResponse struct {
+	Base       string      `json:"base"`
+	Source     string      `json:"source"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	FetchedAt  time.Time   `json:"fetched_at"`
+	Stale      bool        `json:"stale"`
+	Currencies []*Currency `json:"currencies"`
+}
+
+// Currency struct. Rate is a count of base currency units for one unit of currency,
+// it is null if base currency isn't in rate table.
+type Currency struct {
+	Code string  `json:"code"`
+	Rate *string `json:"rate"`
+}
@@ -1,17 +1,38 @@
 package v1
 
-import "time"
+import (
+	"encoding/json"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
 
 type GetHistoryResponse struct {
-	Count   int            `json:"count"`
-	History []*Transaction `json:"history"`
+	// Count is a total count of transactions, it is set only for offset pagination.
+	Count *int `json:"count,omitempty"`
+	// NextCursor is set for cursor pagination if there is next page.
+	NextCursor string         `json:"next_cursor,omitempty"`
+	History    []*Transaction `json:"history"`
 }
 
 type Transaction struct {
-	IDFrom    int       `json:"id_from"`
-	IDTo      int       `json:"id_to"`
-	Amount    float64   `json:"amount"`
-	Currency  string    `json:"currency"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       int64        `json:"id"`
+	IDFrom   int          `json:"id_from"`
+	IDTo     int          `json:"id_to"`
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
+	// RateDate is a date of historical rate used to convert Amount to Currency.
+	RateDate   string          `json:"rate_date,omitempty"`
+	AmountTo   *money.Amount   `json:"amount_to,omitempty"`
+	CurrencyTo string          `json:"currency_to,omitempty"`
+	FXRate     string          `json:"fx_rate,omitempty"`
+	FXRateAt   *time.Time      `json:"fx_rate_at,omitempty"`
+	Comment    string          `json:"comment"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	ReversalOf *int64          `json:"reversal_of,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// CreateTransactionResponse struct.
+type CreateTransactionResponse struct {
+	TransactionID int64 `json:"transaction_id"`
 }
@@ -0,0 +1,20 @@
+package v1
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// Hold struct.
+type Hold struct {
+	ID            int64        `json:"id"`
+	AccountID     int          `json:"account_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	Status        string       `json:"status"`
+	Comment       string       `json:"comment"`
+	TransactionID *int64       `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	ExpiresAt     *time.Time   `json:"expires_at,omitempty"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// GetReconciliationReportsResponse struct.
+type GetReconciliationReportsResponse struct {
+	Reports []*ReconciliationReport `json:"reports"`
+}
+
+// ReconciliationReport struct. Mismatches and Orphans aren't set in list of reports.
+type ReconciliationReport struct {
+	ID            int64                `json:"id"`
+	MismatchCount int                  `json:"mismatch_count"`
+	OrphanCount   int                  `json:"orphan_count"`
+	Mismatches    []*BalanceMismatch   `json:"mismatches,omitempty"`
+	Orphans       []*OrphanTransaction `json:"orphans,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+}
+
+// BalanceMismatch struct. Expected is a net of account postings, Actual is a stored balance.
+type BalanceMismatch struct {
+	AccountID int          `json:"account_id"`
+	Currency  string       `json:"currency"`
+	Expected  money.Amount `json:"expected"`
+	Actual    money.Amount `json:"actual"`
+}
+
+// OrphanTransaction struct. AccountID is 0 if account isn't set in transaction.
+type OrphanTransaction struct {
+	TransactionID int64 `json:"transaction_id"`
+	AccountID     int   `json:"account_id"`
+}
@@ -0,0 +1,31 @@
+package v1
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// GetStatementResponse struct. Period is [From, To).
+type GetStatementResponse struct {
+	AccountID      int                     `json:"account_id"`
+	Currency       string                  `json:"currency"`
+	From           time.Time               `json:"from"`
+	To             time.Time               `json:"to"`
+	OpeningBalance money.Amount            `json:"opening_balance"`
+	ClosingBalance money.Amount            `json:"closing_balance"`
+	TotalCredits   money.Amount            `json:"total_credits"`
+	TotalDebits    money.Amount            `json:"total_debits"`
+	Transactions   []*StatementTransaction `json:"transactions"`
+}
+
+// StatementTransaction struct. Amount is negative for debit, Balance is a running balance after transaction.
+type StatementTransaction struct {
+	ID         int64        `json:"id"`
+	IDFrom     int          `json:"id_from"`
+	IDTo       int          `json:"id_to"`
+	Amount     money.Amount `json:"amount"`
+	Balance    money.Amount `json:"balance"`
+	Comment    string       `json:"comment"`
+	ReversalOf *int64       `json:"reversal_of,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package v1
+
+import (
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/money"
+	"time"
+)
+
+// GetTurnoverResponse struct. AccountID is not set for turnover of all accounts.
+type GetTurnoverResponse struct {
+	AccountID *int        `json:"account_id,omitempty"`
+	Period    string      `json:"period"`
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Turnover  []*Turnover `json:"turnover"`
+}
+
+// Turnover struct.
+type Turnover struct {
+	Period   time.Time    `json:"period"`
+	Currency string       `json:"currency"`
+	Incoming money.Amount `json:"incoming"`
+	Outgoing money.Amount `json:"outgoing"`
+	Net      money.Amount `json:"net"`
+	Count    int          `json:"count"`
+}
@@ -0,0 +1,46 @@
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff is an exponential backoff with full jitter.
+type Backoff struct {
+	// Base is a max delay before first retry, it is doubled on each next retry.
+	Base time.Duration
+	// Max is an upper bound of delay between retries.
+	Max time.Duration
+}
+
+// Delay returns random delay before retry after attempt, attempts are counted from 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+
+	if d > b.Max {
+		d = b.Max
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d))) + 1
+}
+
+// Sleep waits for d or until ctx is done, it returns ctx error in the latter case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
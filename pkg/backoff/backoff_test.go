@@ -0,0 +1,56 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	b := Backoff{Base: 10 * time.Millisecond, Max: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{1, 20 * time.Millisecond},
+		{3, 80 * time.Millisecond},
+		{7, time.Second},
+		{100, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := b.Delay(tt.attempt); d <= 0 || d > tt.max {
+				t.Fatalf("Delay(%d) = %v, want in (0, %v]", tt.attempt, d, tt.max)
+			}
+		}
+	}
+}
+
+func TestDelayZero(t *testing.T) {
+	if d := (Backoff{}).Delay(3); d != 0 {
+		t.Errorf("Delay() of zero Backoff = %v, want 0", d)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+
+	if err := Sleep(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep() error = %v, want context.Canceled", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Error("Sleep() hasn't returned on cancel")
+	}
+}
@@ -0,0 +1,185 @@
+package cbr
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultBaseURL is an address of daily rates feed of Central Bank of Russia.
+const DefaultBaseURL = "https://www.cbr.ru/scripts/XML_daily.asp"
+
+// dateLayout is a format of date in feed.
+const dateLayout = "02.01.2006"
+
+// dateReqLayout is a format of date_req query param.
+const dateReqLayout = "02/01/2006"
+
+// DailyRates is a list of official rates of foreign currencies against RUB.
+type DailyRates struct {
+	Date  time.Time
+	Rates []Rate
+}
+
+// Rate is a price in RUB of Nominal units of currency.
+type Rate struct {
+	CharCode string
+	Nominal  int
+	Value    float64
+}
+
+type valCurs struct {
+	Date    string   `xml:"Date,attr"`
+	Valutes []valute `xml:"Valute"`
+}
+
+type valute struct {
+	CharCode string `xml:"CharCode"`
+	Nominal  string `xml:"Nominal"`
+	Value    string `xml:"Value"`
+}
+
+// Client is a client to daily rates feed of Central Bank of Russia.
+type Client struct {
+	client  *http.Client
+	baseURL string
+}
+
+// New returns new Client to feed at baseURL, DefaultBaseURL is used if it is empty.
+func New(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		client: &http.Client{
+			Timeout: time.Second * 3,
+		},
+		baseURL: baseURL,
+	}
+}
+
+// GetDailyRates returns latest official rates.
+func (c *Client) GetDailyRates(ctx context.Context) (*DailyRates, error) {
+	return c.getRates(ctx, c.baseURL)
+}
+
+// GetRatesOnDate returns official rates set on date. Rates aren't set on weekends and holidays,
+// so Date of result can be earlier than date.
+func (c *Client) GetRatesOnDate(ctx context.Context, date time.Time) (*DailyRates, error) {
+	query := url.Values{}
+	query.Set("date_req", date.Format(dateReqLayout))
+
+	return c.getRates(ctx, c.baseURL+"?"+query.Encode())
+}
+
+func (c *Client) getRates(ctx context.Context, u string) (*DailyRates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get daily rates: status %d", resp.StatusCode)
+	}
+
+	var vc valCurs
+
+	d := xml.NewDecoder(resp.Body)
+	d.CharsetReader = charsetReader
+
+	if err := d.Decode(&vc); err != nil {
+		return nil, err
+	}
+
+	return vc.parse()
+}
+
+func (vc *valCurs) parse() (*DailyRates, error) {
+	date, err := time.ParseInLocation(dateLayout, vc.Date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date of daily rates: %w", err)
+	}
+
+	r := DailyRates{Date: date}
+
+	for _, v := range vc.Valutes {
+		nominal, err := strconv.Atoi(strings.TrimSpace(v.Nominal))
+		if err != nil || nominal <= 0 {
+			return nil, fmt.Errorf("invalid nominal of %s", v.CharCode)
+		}
+
+		// Feed uses comma as decimal separator.
+		value, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(v.Value), ",", ".", 1), 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid value of %s", v.CharCode)
+		}
+
+		r.Rates = append(r.Rates, Rate{
+			CharCode: strings.TrimSpace(v.CharCode),
+			Nominal:  nominal,
+			Value:    value,
+		})
+	}
+
+	if len(r.Rates) == 0 {
+		return nil, errors.New("daily rates are empty")
+	}
+
+	return &r, nil
+}
+
+// charsetReader decodes windows-1251 which is used by feed.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	if !strings.EqualFold(charset, "windows-1251") {
+		return nil, fmt.Errorf("unsupported charset %s", charset)
+	}
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var out strings.Builder
+	out.Grow(len(data) * 2)
+
+	for _, b := range data {
+		out.WriteRune(decodeWindows1251(b))
+	}
+
+	return strings.NewReader(out.String()), nil
+}
+
+// windows1251High maps bytes 0x80-0xBF of windows-1251, bytes from 0xC0 are А-я.
+var windows1251High = [64]rune{
+	'Ђ', 'Ѓ', '‚', 'ѓ', '„', '…', '†', '‡', '€', '‰', 'Љ', '‹', 'Њ', 'Ќ', 'Ћ', 'Џ',
+	'ђ', '‘', '’', '“', '”', '•', '–', '—', utf8.RuneError, '™', 'љ', '›', 'њ', 'ќ', 'ћ', 'џ',
+	'\u00a0', 'Ў', 'ў', 'Ј', '¤', 'Ґ', '¦', '§', 'Ё', '©', 'Є', '«', '¬', '\u00ad', '®', 'Ї',
+	'°', '±', 'І', 'і', 'ґ', 'µ', '¶', '·', 'ё', '№', 'є', '»', 'ј', 'Ѕ', 'ѕ', 'ї',
+}
+
+func decodeWindows1251(b byte) rune {
+	switch {
+	case b < 0x80:
+		return rune(b)
+	case b < 0xC0:
+		return windows1251High[b-0x80]
+	default:
+		return 'А' + rune(b-0xC0)
+	}
+}
@@ -0,0 +1,143 @@
+package cbr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// windows1251 encodes ASCII and Cyrillic А-я to windows-1251.
+func windows1251(s string) []byte {
+	var b []byte
+
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			b = append(b, byte(r))
+		case r >= 'А' && r <= 'я':
+			b = append(b, byte(r-'А'+0xC0))
+		default:
+			panic("unsupported rune")
+		}
+	}
+
+	return b
+}
+
+const dailyFeed = `<?xml version="1.0" encoding="windows-1251"?>
+<ValCurs Date="02.10.2021" name="Foreign Currency Market">
+<Valute ID="R01235"><NumCode>840</NumCode><CharCode>USD</CharCode><Nominal>1</Nominal><Name>Доллар США</Name><Value>72,7608</Value></Valute>
+<Valute ID="R01820"><NumCode>392</NumCode><CharCode>JPY</CharCode><Nominal>100</Nominal><Name>Японских иен</Name><Value>65,1234</Value></Valute>
+</ValCurs>`
+
+func newFeedServer(t *testing.T, status int, body []byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=windows-1251")
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGetDailyRates(t *testing.T) {
+	srv := newFeedServer(t, http.StatusOK, windows1251(dailyFeed))
+
+	r, err := New(srv.URL).GetDailyRates(context.Background())
+	if err != nil {
+		t.Fatalf("GetDailyRates() error = %v", err)
+	}
+
+	wantDate := time.Date(2021, 10, 2, 0, 0, 0, 0, time.Local)
+	if !r.Date.Equal(wantDate) {
+		t.Errorf("Date = %v, want %v", r.Date, wantDate)
+	}
+
+	want := []Rate{
+		{CharCode: "USD", Nominal: 1, Value: 72.7608},
+		{CharCode: "JPY", Nominal: 100, Value: 65.1234},
+	}
+
+	if len(r.Rates) != len(want) {
+		t.Fatalf("len(Rates) = %d, want %d", len(r.Rates), len(want))
+	}
+
+	for i := range want {
+		if r.Rates[i] != want[i] {
+			t.Errorf("Rates[%d] = %+v, want %+v", i, r.Rates[i], want[i])
+		}
+	}
+}
+
+func TestGetRatesOnDate(t *testing.T) {
+	var dateReq string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dateReq = r.URL.Query().Get("date_req")
+		_, _ = w.Write(windows1251(dailyFeed))
+	}))
+	defer srv.Close()
+
+	// Rates of Sunday are rates set on Saturday.
+	r, err := New(srv.URL).GetRatesOnDate(context.Background(), time.Date(2021, 10, 3, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("GetRatesOnDate() error = %v", err)
+	}
+
+	if dateReq != "03/10/2021" {
+		t.Errorf("date_req = %q, want 03/10/2021", dateReq)
+	}
+
+	if wantDate := time.Date(2021, 10, 2, 0, 0, 0, 0, time.Local); !r.Date.Equal(wantDate) {
+		t.Errorf("Date = %v, want %v", r.Date, wantDate)
+	}
+}
+
+func TestGetDailyRatesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   []byte
+	}{
+		{"status", http.StatusInternalServerError, nil},
+		{"malformed", http.StatusOK, []byte("<ValCurs")},
+		{"unsupported charset", http.StatusOK, []byte(`<?xml version="1.0" encoding="koi8-r"?><ValCurs Date="02.10.2021"></ValCurs>`)},
+		{"empty", http.StatusOK, []byte(`<ValCurs Date="02.10.2021"></ValCurs>`)},
+		{"invalid date", http.StatusOK, []byte(`<ValCurs Date="2021-10-02"></ValCurs>`)},
+		{"invalid value", http.StatusOK, []byte(`<ValCurs Date="02.10.2021"><Valute><CharCode>USD</CharCode><Nominal>1</Nominal><Value>abc</Value></Valute></ValCurs>`)},
+		{"zero nominal", http.StatusOK, []byte(`<ValCurs Date="02.10.2021"><Valute><CharCode>USD</CharCode><Nominal>0</Nominal><Value>1,5</Value></Valute></ValCurs>`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFeedServer(t, tt.status, tt.body)
+
+			if _, err := New(srv.URL).GetDailyRates(context.Background()); err == nil {
+				t.Error("GetDailyRates() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestCharsetReader(t *testing.T) {
+	r, err := charsetReader("Windows-1251", bytes.NewReader(append(windows1251("Да "), 0xA8, 0xB8, 0xB9)))
+	if err != nil {
+		t.Fatalf("charsetReader() error = %v", err)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	if got, want := string(b), "Да Ёё№"; got != want {
+		t.Errorf("decoded = %q, want %q", got, want)
+	}
+}
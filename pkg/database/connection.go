@@ -9,16 +9,18 @@ import (
 // DB is a Postgres connection pool.
 type DB struct {
 	Pool *pgxpool.Pool
+	// MaxTxRetries is a max count of retries of transaction run by InTx.
+	MaxTxRetries int
 }
 
 // New returns new Postgres connection pool.
-func New(ctx context.Context, url string) (*DB, error) {
+func New(ctx context.Context, url string, maxTxRetries int) (*DB, error) {
 	db, err := pgxpool.Connect(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connection to database: %s", err)
 	}
 
-	return &DB{db}, err
+	return &DB{Pool: db, MaxTxRetries: maxTxRetries}, err
 }
 
 func (db *DB) Close() {
@@ -2,13 +2,36 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/backoff"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+	"time"
 )
 
+// retryBackoff is a backoff of retried transactions.
+var retryBackoff = backoff.Backoff{Base: 10 * time.Millisecond, Max: time.Second}
+
 // InTx runs the given function f within a transaction with the provided
 // isolation level isoLevel.
+//
+// Transaction is retried from the start up to MaxTxRetries times if it fails with
+// serialization failure or deadlock, so f must not depend on state changed by previous attempt.
 func (db *DB) InTx(ctx context.Context, isoLevel pgx.TxIsoLevel, f func(tx pgx.Tx) error) error {
+	for attempt := 0; ; attempt++ {
+		err := db.inTx(ctx, isoLevel, f)
+		if err == nil || attempt >= db.MaxTxRetries || !isRetryable(err) {
+			return err
+		}
+
+		if backoff.Sleep(ctx, retryBackoff.Delay(attempt)) != nil {
+			return err
+		}
+	}
+}
+
+func (db *DB) inTx(ctx context.Context, isoLevel pgx.TxIsoLevel, f func(tx pgx.Tx) error) error {
 	conn, err := db.Pool.Acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("acquiring connection: %w", err)
@@ -32,3 +55,13 @@ func (db *DB) InTx(ctx context.Context, isoLevel pgx.TxIsoLevel, f func(tx pgx.T
 	}
 	return nil
 }
+
+// isRetryable reports whether transaction failed with serialization_failure or deadlock_detected.
+func isRetryable(err error) bool {
+	var pgerr *pgconn.PgError
+	if !errors.As(err, &pgerr) {
+		return false
+	}
+
+	return pgerr.Code == "40001" || pgerr.Code == "40P01"
+}
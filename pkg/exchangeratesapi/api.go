@@ -1,13 +1,27 @@
 package exchangeratesapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/EpicStep/avito-autumn-2021-intern-task/pkg/backoff"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// DefaultBaseURL is an address of ExchangeratesAPI.
+const DefaultBaseURL = "https://api.exchangeratesapi.io/v1"
+
+// maxRetries is a max count of retries of failed request.
+const maxRetries = 3
+
+// retryBackoff is a backoff of retried requests.
+var retryBackoff = backoff.Backoff{Base: 200 * time.Millisecond, Max: 5 * time.Second}
+
 // GetCurrencyListResponse struct.
 type GetCurrencyListResponse struct {
 	Success   bool               `json:"success"`
@@ -17,39 +31,154 @@ type GetCurrencyListResponse struct {
 	Rates     map[string]float64 `json:"rates"`
 }
 
+// APIError is an error returned by API, for example:
+//
+//	{"success": false, "error": {"code": 101, "type": "invalid_access_key", "info": "..."}}
+//
+// Code, Type and Info are empty if response has no error object.
+type APIError struct {
+	StatusCode int
+	Code       int    `json:"code"`
+	Type       string `json:"type"`
+	Info       string `json:"info"`
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("exchangeratesapi: status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("exchangeratesapi: %s (code %d, status %d): %s", e.Type, e.Code, e.StatusCode, e.Info)
+}
+
+// Temporary reports whether request can succeed if it is retried.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+type errorResponse struct {
+	Success *bool     `json:"success"`
+	Error   *APIError `json:"error"`
+}
+
 // ExchangerAPI is a client to ExchangeratesAPI.
 type ExchangerAPI struct {
-	client *http.Client
-	token  string
+	client  *http.Client
+	token   string
+	baseURL string
+	// retryDelay returns delay before retry after attempt.
+	retryDelay func(attempt int) time.Duration
 }
 
-// New returns new ExchangerAPI.
-func New(token string) *ExchangerAPI {
+// New returns new ExchangerAPI to API at baseURL, DefaultBaseURL is used if it is empty.
+func New(token string, baseURL string) *ExchangerAPI {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
 	return &ExchangerAPI{
 		client: &http.Client{
 			Timeout: time.Second * 3,
 		},
-		token: token,
+		token:      token,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		retryDelay: retryBackoff.Delay,
 	}
 }
 
 // GetCurrencyList returns GetCurrencyListResponse.
-func (c *ExchangerAPI) GetCurrencyList() (*GetCurrencyListResponse, error) {
-	var r GetCurrencyListResponse
+func (c *ExchangerAPI) GetCurrencyList(ctx context.Context) (*GetCurrencyListResponse, error) {
+	return c.getCurrencyList(ctx, "latest")
+}
+
+// GetHistoricalCurrencyList returns GetCurrencyListResponse with rates of date.
+func (c *ExchangerAPI) GetHistoricalCurrencyList(ctx context.Context, date time.Time) (*GetCurrencyListResponse, error) {
+	return c.getCurrencyList(ctx, date.Format("2006-01-02"))
+}
+
+// getCurrencyList requests rates at endpoint, request is retried if it fails with network or temporary API error.
+func (c *ExchangerAPI) getCurrencyList(ctx context.Context, endpoint string) (*GetCurrencyListResponse, error) {
+	for attempt := 0; ; attempt++ {
+		r, err := c.doGetCurrencyList(ctx, endpoint)
+		if err == nil || attempt >= maxRetries || !isRetryable(ctx, err) {
+			return r, err
+		}
+
+		if backoff.Sleep(ctx, c.retryDelay(attempt)) != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *ExchangerAPI) doGetCurrencyList(ctx context.Context, endpoint string) (*GetCurrencyListResponse, error) {
+	query := url.Values{}
+	query.Set("access_key", c.token)
 
-	resp, err := http.Get(fmt.Sprintf("http://api.exchangeratesapi.io/v1/latest?access_key=%s&format=1", c.token))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/"+endpoint+"?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New("failed to get currency list")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			// Token mustn't get to logs with error.
+			urlErr.URL = c.baseURL + "/" + endpoint
+		}
+
+		return nil, err
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&r)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	var errResp errorResponse
+
+	// API can report error with status 200, so body is checked even if status is successful.
+	_ = json.Unmarshal(body, &errResp)
+
+	if resp.StatusCode != http.StatusOK || (errResp.Success != nil && !*errResp.Success) {
+		apiErr := errResp.Error
+		if apiErr == nil {
+			apiErr = &APIError{}
+		}
+
+		apiErr.StatusCode = resp.StatusCode
+
+		return nil, apiErr
+	}
+
+	var r GetCurrencyListResponse
+
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+
+	if len(r.Rates) == 0 {
+		return nil, errors.New("exchangeratesapi: response has no rates")
+	}
+
 	return &r, nil
 }
+
+// isRetryable reports whether request failed with network or temporary API error.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+
+	var urlErr *url.Error
+
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,184 @@
+package exchangeratesapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const latestResponse = `{"success": true, "timestamp": 1633046400, "base": "EUR", "date": "2021-10-01", "rates": {"RUB": 84.5}}`
+
+// newTestAPI returns client to server which answers with statuses and bodies of responses in order,
+// the last response is repeated. Count of requests is stored to calls.
+func newTestAPI(t *testing.T, calls *int32, statuses []int, bodies []string) *ExchangerAPI {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(calls, 1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+
+		w.WriteHeader(statuses[i])
+		_, _ = w.Write([]byte(bodies[i]))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := New("secret-token", srv.URL)
+	c.retryDelay = func(int) time.Duration { return time.Millisecond }
+
+	return c
+}
+
+func TestGetCurrencyList(t *testing.T) {
+	var calls int32
+
+	c := newTestAPI(t, &calls, []int{http.StatusOK}, []string{latestResponse})
+
+	r, err := c.GetCurrencyList(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrencyList() error = %v", err)
+	}
+
+	if r.Base != "EUR" || r.Rates["RUB"] != 84.5 || r.Timestamp != 1633046400 {
+		t.Errorf("GetCurrencyList() = %+v", r)
+	}
+}
+
+func TestGetCurrencyListRetry(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			var calls int32
+
+			c := newTestAPI(t, &calls, []int{status, status, http.StatusOK}, []string{"", "", latestResponse})
+
+			if _, err := c.GetCurrencyList(context.Background()); err != nil {
+				t.Fatalf("GetCurrencyList() error = %v", err)
+			}
+
+			if calls != 3 {
+				t.Errorf("calls = %d, want 3", calls)
+			}
+		})
+	}
+}
+
+func TestGetCurrencyListRetriesExhausted(t *testing.T) {
+	var calls int32
+
+	c := newTestAPI(t, &calls, []int{http.StatusBadGateway}, []string{""})
+
+	_, err := c.GetCurrencyList(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("GetCurrencyList() error = %v, want APIError with status 502", err)
+	}
+
+	if calls != maxRetries+1 {
+		t.Errorf("calls = %d, want %d", calls, maxRetries+1)
+	}
+}
+
+func TestGetCurrencyListNoRetry(t *testing.T) {
+	var calls int32
+
+	c := newTestAPI(t, &calls, []int{http.StatusUnauthorized},
+		[]string{`{"success": false, "error": {"code": 101, "type": "invalid_access_key", "info": "Invalid key."}}`})
+
+	_, err := c.GetCurrencyList(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetCurrencyList() error = %v, want APIError", err)
+	}
+
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != 101 || apiErr.Type != "invalid_access_key" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestGetCurrencyListErrorWithStatusOK(t *testing.T) {
+	var calls int32
+
+	c := newTestAPI(t, &calls, []int{http.StatusOK},
+		[]string{`{"success": false, "error": {"code": 105, "type": "https_access_restricted", "info": "Not supported."}}`})
+
+	_, err := c.GetHistoricalCurrencyList(context.Background(), time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetHistoricalCurrencyList() error = %v, want APIError", err)
+	}
+
+	if apiErr.Code != 105 || apiErr.Type != "https_access_restricted" || apiErr.Temporary() {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestGetCurrencyListScrubsToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c := New("secret-token", srv.URL)
+	c.retryDelay = func(int) time.Duration { return time.Millisecond }
+
+	_, err := c.GetCurrencyList(context.Background())
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("GetCurrencyList() error = %v, want url.Error", err)
+	}
+
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("error %q contains token", err)
+	}
+}
+
+func TestGetCurrencyListContextCanceledDuringBackoff(t *testing.T) {
+	var calls int32
+
+	c := newTestAPI(t, &calls, []int{http.StatusServiceUnavailable}, []string{""})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c.retryDelay = func(int) time.Duration {
+		cancel()
+		return time.Hour
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := c.GetCurrencyList(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("GetCurrencyList() error = nil, want error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetCurrencyList() didn't return after context cancel")
+	}
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
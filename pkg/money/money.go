@@ -0,0 +1,243 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgtype"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is a count of fractional digits kept by Amount.
+const Scale = 2
+
+// minorInMajor is a count of minor units (kopecks, cents) in one major unit.
+const minorInMajor = 100
+
+// ErrInvalidAmount error.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ErrTooManyFractionalDigits error.
+var ErrTooManyFractionalDigits = fmt.Errorf("%w: must have at most 2 fractional digits", ErrInvalidAmount)
+
+// ErrOutOfRange error.
+var ErrOutOfRange = fmt.Errorf("%w: out of range", ErrInvalidAmount)
+
+// Amount is an exact decimal money value stored in minor units.
+//
+// It is scanned from and written to Postgres as numeric, and serialized to JSON as string.
+type Amount int64
+
+// Parse decimal string like "-12.5" to Amount.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+
+	if intPart == "" && fracPart == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalidAmount
+	}
+
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > Scale {
+		return 0, ErrTooManyFractionalDigits
+	}
+
+	fracPart += strings.Repeat("0", Scale-len(fracPart))
+
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	major, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || major > math.MaxInt64/minorInMajor-1 {
+		return 0, ErrOutOfRange
+	}
+
+	minor, _ := strconv.ParseInt(fracPart, 10, 64)
+
+	result := major*minorInMajor + minor
+	if neg {
+		result = -result
+	}
+
+	return Amount(result), nil
+}
+
+// String returns amount as decimal string with exactly 2 fractional digits.
+func (a Amount) String() string {
+	v := int64(a)
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+	}
+
+	u := uint64(v)
+	if v < 0 {
+		u = uint64(-v)
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, u/minorInMajor, u%minorInMajor)
+}
+
+// Rat returns amount as exact rational number.
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), minorInMajor)
+}
+
+// FromRat rounds rational number half away from zero to Amount.
+func FromRat(r *big.Rat) (Amount, error) {
+	scaled := new(big.Rat).Mul(r, big.NewRat(minorInMajor, 1))
+
+	num := new(big.Int).Abs(scaled.Num())
+	den := scaled.Denom()
+
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(m, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+
+	if scaled.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrOutOfRange
+	}
+
+	return Amount(q.Int64()), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both JSON strings and numbers are accepted.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	if strings.ContainsAny(s, "eE") {
+		return ErrInvalidAmount
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = v
+	return nil
+}
+
+// EncodeBinary implements pgtype.BinaryEncoder.
+func (a Amount) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return a.numeric().EncodeBinary(ci, buf)
+}
+
+// EncodeText implements pgtype.TextEncoder.
+func (a Amount) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return append(buf, a.String()...), nil
+}
+
+// DecodeBinary implements pgtype.BinaryDecoder.
+func (a *Amount) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	var n pgtype.Numeric
+	if err := n.DecodeBinary(ci, src); err != nil {
+		return err
+	}
+
+	return a.fromNumeric(&n)
+}
+
+// DecodeText implements pgtype.TextDecoder.
+func (a *Amount) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	var n pgtype.Numeric
+	if err := n.DecodeText(ci, src); err != nil {
+		return err
+	}
+
+	return a.fromNumeric(&n)
+}
+
+func (a Amount) numeric() *pgtype.Numeric {
+	return &pgtype.Numeric{
+		Int:    big.NewInt(int64(a)),
+		Exp:    -Scale,
+		Status: pgtype.Present,
+	}
+}
+
+func (a *Amount) fromNumeric(n *pgtype.Numeric) error {
+	if n.Status != pgtype.Present {
+		return fmt.Errorf("cannot scan NULL into %T", a)
+	}
+
+	if n.NaN {
+		return ErrInvalidAmount
+	}
+
+	v := new(big.Int).Set(n.Int)
+	exp := int(n.Exp) + Scale
+
+	for ; exp > 0; exp-- {
+		v.Mul(v, big.NewInt(10))
+	}
+
+	for ; exp < 0; exp++ {
+		var m big.Int
+		v.QuoRem(v, big.NewInt(10), &m)
+		if m.Sign() != 0 {
+			return ErrTooManyFractionalDigits
+		}
+	}
+
+	if !v.IsInt64() {
+		return ErrOutOfRange
+	}
+
+	*a = Amount(v.Int64())
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,213 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgtype"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr error
+	}{
+		{"0", 0, nil},
+		{"12", 1200, nil},
+		{"12.5", 1250, nil},
+		{"12.50", 1250, nil},
+		{"12.500", 1250, nil},
+		{"-12.5", -1250, nil},
+		{"+12.05", 1205, nil},
+		{".5", 50, nil},
+		{"5.", 500, nil},
+		{" 1.01 ", 101, nil},
+		{"-0.01", -1, nil},
+		{"92233720368547757.99", 9223372036854775799, nil},
+		{"1.001", 0, ErrTooManyFractionalDigits},
+		{"-0.125", 0, ErrTooManyFractionalDigits},
+		{"92233720368547758", 0, ErrOutOfRange},
+		{"-99999999999999999999", 0, ErrOutOfRange},
+		{"", 0, ErrInvalidAmount},
+		{"-", 0, ErrInvalidAmount},
+		{".", 0, ErrInvalidAmount},
+		{"1,5", 0, ErrInvalidAmount},
+		{"1.2.3", 0, ErrInvalidAmount},
+		{"--1", 0, ErrInvalidAmount},
+		{"1e2", 0, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1250, "12.50"},
+		{-1, "-0.01"},
+		{-1250, "-12.50"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %s, want %s", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestFromRat(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *big.Rat
+		want Amount
+	}{
+		{"exact", big.NewRat(1250, 100), 1250},
+		{"half up", big.NewRat(1005, 1000), 101},
+		{"below half", big.NewRat(1004, 1000), 100},
+		{"negative half away from zero", big.NewRat(-1005, 1000), -101},
+		{"negative below half", big.NewRat(-1004, 1000), -100},
+		{"negative third", big.NewRat(-1, 3), -33},
+		{"negative two thirds", big.NewRat(-2, 3), -67},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromRat(tt.in)
+			if err != nil {
+				t.Fatalf("FromRat(%s) error = %v", tt.in, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("FromRat(%s) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	huge := new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), 64))
+	if _, err := FromRat(huge); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("FromRat(2^64) error = %v, want %v", err, ErrOutOfRange)
+	}
+}
+
+func TestFromNumeric(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       pgtype.Numeric
+		want    Amount
+		wantErr error
+	}{
+		{"scale of column", pgtype.Numeric{Int: big.NewInt(1250), Exp: -2, Status: pgtype.Present}, 1250, nil},
+		{"positive exponent", pgtype.Numeric{Int: big.NewInt(12), Exp: 3, Status: pgtype.Present}, 1200000, nil},
+		{"negative", pgtype.Numeric{Int: big.NewInt(-125), Exp: -1, Status: pgtype.Present}, -1250, nil},
+		{"trailing zeros", pgtype.Numeric{Int: big.NewInt(-12500), Exp: -4, Status: pgtype.Present}, -125, nil},
+		{"too many digits", pgtype.Numeric{Int: big.NewInt(12345), Exp: -3, Status: pgtype.Present}, 0, ErrTooManyFractionalDigits},
+		{"out of range", pgtype.Numeric{Int: big.NewInt(1), Exp: 20, Status: pgtype.Present}, 0, ErrOutOfRange},
+		{"NaN", pgtype.Numeric{NaN: true, Status: pgtype.Present}, 0, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Amount
+
+			err := a.fromNumeric(&tt.n)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("fromNumeric() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if a != tt.want {
+				t.Errorf("fromNumeric() = %d, want %d", a, tt.want)
+			}
+		})
+	}
+
+	var a Amount
+	if err := a.fromNumeric(&pgtype.Numeric{Status: pgtype.Null}); err == nil {
+		t.Error("fromNumeric(NULL) error = nil, want error")
+	}
+}
+
+func TestNumericRoundTrip(t *testing.T) {
+	for _, want := range []Amount{0, 1, -1, 1250, -9223372036854775807} {
+		buf, err := want.EncodeBinary(nil, nil)
+		if err != nil {
+			t.Fatalf("EncodeBinary(%s) error = %v", want, err)
+		}
+
+		var got Amount
+		if err := got.DecodeBinary(nil, buf); err != nil {
+			t.Fatalf("DecodeBinary(%s) error = %v", want, err)
+		}
+
+		if got != want {
+			t.Errorf("binary round trip of %s = %s", want, got)
+		}
+
+		text, _ := want.EncodeText(nil, nil)
+		if err := got.DecodeText(nil, text); err != nil || got != want {
+			t.Errorf("text round trip of %s = %s, %v", want, got, err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type request struct {
+		Amount Amount `json:"amount"`
+	}
+
+	data, err := json.Marshal(request{Amount: -1250})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if got, want := string(data), `{"amount":"-12.50"}`; got != want {
+		t.Errorf("Marshal() = %s, want %s", got, want)
+	}
+
+	var r request
+	if err := json.Unmarshal(data, &r); err != nil || r.Amount != -1250 {
+		t.Errorf("Unmarshal(%s) = %d, %v, want -1250", data, r.Amount, err)
+	}
+
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{`{"amount": "10.05"}`, 1005, false},
+		{`{"amount": 10.05}`, 1005, false},
+		{`{"amount": null}`, 0, false},
+		{`{"amount": 1e2}`, 0, true},
+		{`{"amount": "10.005"}`, 0, true},
+		{`{"amount": "abc"}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		var r request
+
+		err := json.Unmarshal([]byte(tt.in), &r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Unmarshal(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+
+		if r.Amount != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, r.Amount, tt.want)
+		}
+	}
+}